@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	// approvalExpiryInterval is the interval between two checks of the expired approval requests
+	approvalExpiryInterval = 5 * time.Minute
+
+	// defaultApprovalExpiry is how long an approval request waits for a review, if not configured
+	defaultApprovalExpiry = 24 * time.Hour
+
+	approvalActionApprove = "approve"
+	approvalActionReject  = "reject"
+)
+
+// approvalRequest is a large deletion waiting for the approval of a second user.
+// It is kept in the KV store only while pending
+type approvalRequest struct {
+	ID                string      `json:"id"`
+	UserID            string      `json:"user_id"`
+	ChannelID         string      `json:"channel_id"`
+	PostIDs           []string    `json:"post_ids"`
+	NumPosts          int         `json:"num_posts"`
+	Filters           postFilters `json:"filters"`
+	DeletePinnedPosts bool        `json:"delete_pinned_posts"`
+	ArchiveFormat     string      `json:"archive_format,omitempty"`
+	MoveChannelID     string      `json:"move_channel_id,omitempty"`
+	Command           string      `json:"command"`
+	ApprovalPostID    string      `json:"approval_post_id"`
+	CreateAt          int64       `json:"create_at"`
+	ExpireAt          int64       `json:"expire_at"`
+}
+
+func getApprovalKey(requestID string) string {
+	return kvApprovalPrefix + requestID
+}
+
+// getApprovalExpiry returns how long an approval request waits for a review
+func (p *Plugin) getApprovalExpiry() time.Duration {
+	if hours := p.getConfiguration().ApprovalExpiryHours; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+
+	return defaultApprovalExpiry
+}
+
+// requiresApproval tells if deleting the posts of postList matching the criteria of options
+// has to be approved by a second user. The posts are counted like for the quota, with the replies
+// deleted along with their thread
+func (p *Plugin) requiresApproval(postList *model.PostList, options *deletionOptions) bool {
+	conf := p.getConfiguration()
+	if conf.ApprovalThreshold <= 0 || conf.ApproversChannelID == "" {
+		return false
+	}
+
+	return p.getNumPostsToDelete(postList, options) > conf.ApprovalThreshold
+}
+
+// requestApproval keeps the selected posts of postList pending, and asks the approvers to review the deletion.
+// Returns false if the request could not be submitted
+func (p *Plugin) requestApproval(postList *model.PostList, options *deletionOptions) bool {
+	selected := p.selectPostsToDelete(postList, options, new(deletePostResult))
+	now := time.Now()
+
+	request := &approvalRequest{
+		ID:                model.NewId(),
+		UserID:            options.userID,
+		ChannelID:         options.channelID,
+		PostIDs:           selected.Order,
+		NumPosts:          p.getNumPostsToDelete(postList, options),
+		Filters:           options.filters,
+		DeletePinnedPosts: options.optDeletePinnedPosts,
+		ArchiveFormat:     options.archiveFormat,
+		MoveChannelID:     options.moveChannelID,
+		Command:           options.command,
+		CreateAt:          now.UnixMilli(),
+		ExpireAt:          now.Add(p.getApprovalExpiry()).UnixMilli(),
+	}
+
+	approvalPost, appErr := p.API.CreatePost(p.getApprovalPost(request, p.getSelectionReport(postList, options)))
+	if appErr != nil {
+		p.API.LogError("Unable to post approval request", "appErr", appErr)
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when requesting the approval of the housecleaning")
+		return false
+	}
+	request.ApprovalPostID = approvalPost.Id
+
+	if _, err := p.client.KV.Set(getApprovalKey(request.ID), request); err != nil {
+		p.API.LogError("Unable to save approval request", "err", err)
+		p.updateApprovalPost(request, "Error when saving this request, it can't be reviewed.")
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when requesting the approval of the housecleaning")
+		return false
+	}
+
+	p.sendEphemeralPost(options.userID, options.channelID, fmt.Sprintf(
+		"This housecleaning would delete %d posts, so it has to be approved by someone else in ~%s first. The request expires in %s.",
+		request.NumPosts, p.getChannelName(p.getConfiguration().ApproversChannelID), p.getApprovalExpiry(),
+	))
+
+	return true
+}
+
+// getApprovalPost returns the post asking the approvers to review the request
+func (p *Plugin) getApprovalPost(request *approvalRequest, report string) *model.Post {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	actionURL := fmt.Sprintf("%s/plugins/%s%s", *siteURL, manifest.Id, routeApprovalAction)
+
+	message := fmt.Sprintf(
+		"%s wants to delete %d posts in ~%s. Another authorized user has to approve it.",
+		p.getUserMention(request.UserID), request.NumPosts, p.getChannelName(request.ChannelID),
+	)
+	if request.MoveChannelID != "" {
+		message = fmt.Sprintf(
+			"%s wants to move %d posts from ~%s to ~%s. Another authorized user has to approve it.",
+			p.getUserMention(request.UserID), request.NumPosts, p.getChannelName(request.ChannelID),
+			p.getChannelName(request.MoveChannelID),
+		)
+	}
+
+	post := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: p.getConfiguration().ApproversChannelID,
+		Message:   message,
+	}
+
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{{
+		Text: report,
+		Actions: []*model.PostAction{
+			{
+				Id:    approvalActionApprove,
+				Name:  "Approve",
+				Style: "danger",
+				Integration: &model.PostActionIntegration{
+					URL:     actionURL,
+					Context: map[string]any{"request_id": request.ID, "action": approvalActionApprove},
+				},
+			},
+			{
+				Id:    approvalActionReject,
+				Name:  "Reject",
+				Style: "default",
+				Integration: &model.PostActionIntegration{
+					URL:     actionURL,
+					Context: map[string]any{"request_id": request.ID, "action": approvalActionReject},
+				},
+			},
+		},
+	}})
+
+	return post
+}
+
+// updateApprovalPost replaces the buttons of the approval post by the outcome of the request
+func (p *Plugin) updateApprovalPost(request *approvalRequest, outcome string) {
+	post, appErr := p.API.GetPost(request.ApprovalPostID)
+	if appErr != nil {
+		p.API.LogError("Unable to get approval post", "PostID", request.ApprovalPostID, "appErr", appErr)
+		return
+	}
+
+	post.Message += "\n\n" + outcome
+	model.ParseSlackAttachment(post, nil)
+
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		p.API.LogError("Unable to update approval post", "PostID", post.Id, "appErr", appErr)
+	}
+}
+
+// getApprovalRequest returns the pending request, or nil if it has been reviewed or has expired
+func (p *Plugin) getApprovalRequest(requestID string) (*approvalRequest, error) {
+	var request *approvalRequest
+	if err := p.client.KV.Get(getApprovalKey(requestID), &request); err != nil {
+		return nil, err
+	}
+
+	// The expiry job may not have rejected it yet
+	if request != nil && request.ExpireAt < model.GetMillis() {
+		return nil, nil
+	}
+
+	return request, nil
+}
+
+// takeApprovalRequest removes the pending request from the KV store and returns it.
+// Returns nil if it has already been taken, so a request is only reviewed once
+func (p *Plugin) takeApprovalRequest(requestID string) (*approvalRequest, error) {
+	key := getApprovalKey(requestID)
+	data, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var request *approvalRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, err
+	}
+
+	deleted, err := p.client.KV.Set(key, nil, pluginapi.SetAtomic(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted {
+		return nil, nil
+	}
+
+	return request, nil
+}
+
+// canReviewApproval tells if the user may approve or reject the request
+func (p *Plugin) canReviewApproval(userID string, request *approvalRequest) bool {
+	if userID == request.UserID {
+		return false
+	}
+
+	channel, appErr := p.API.GetChannel(request.ChannelID)
+	if appErr != nil {
+		p.API.LogError("Unable to get channel", "ChannelID", request.ChannelID, "appErr", appErr)
+		return false
+	}
+
+	return isAllowedToBroom(p, userID, channel.TeamId, channel.Id) && canDeleteOthersPosts(p, userID, channel.Id)
+}
+
+// runApprovedDeletion deletes the posts of the approved request in a background job
+func (p *Plugin) runApprovedDeletion(request *approvalRequest, reviewerID string) {
+	options := &deletionOptions{
+		channelID:             request.ChannelID,
+		userID:                request.UserID,
+		filters:               request.Filters,
+		optDeletePinnedPosts:  request.DeletePinnedPosts,
+		archiveFormat:         request.ArchiveFormat,
+		moveChannelID:         request.MoveChannelID,
+		command:               request.Command,
+		permDeleteOthersPosts: canDeleteOthersPosts(p, request.UserID, request.ChannelID),
+	}
+
+	if !canDeletePost(p, options.userID, options.channelID) {
+		p.sendEphemeralPost(options.userID, options.channelID, "Your housecleaning has been approved, but you are not permitted to delete posts anymore")
+		return
+	}
+
+	if options.moveChannelID != "" && !p.API.HasPermissionToChannel(options.userID, options.moveChannelID, model.PermissionCreatePost) {
+		p.sendEphemeralPost(options.userID, options.channelID, "Your housecleaning has been approved, but you are not permitted to post in the target channel anymore")
+		return
+	}
+
+	postList := model.NewPostList()
+	for _, postID := range request.PostIDs {
+		post, appErr := p.API.GetPost(postID)
+		if appErr != nil || post.ChannelId != request.ChannelID {
+			continue // deleted meanwhile
+		}
+
+		postList.AddPost(post)
+		postList.AddOrder(post.Id)
+	}
+
+	if userErr := p.reserveQuotaForPosts(postList, options); userErr != nil {
+		p.sendEphemeralPost(options.userID, options.channelID, "Your housecleaning has been approved, but "+userErr.Error())
+		return
+	}
+
+	p.sendEphemeralPost(options.userID, options.channelID, fmt.Sprintf(
+		"%s approved your housecleaning of %d posts.", p.getUserMention(reviewerID), request.NumPosts,
+	))
+	p.startDeletionJob(postList, options)
+}
+
+// expireApprovalRequests rejects the requests which have not been reviewed in time.
+// It is run periodically as a background job
+func (p *Plugin) expireApprovalRequests() {
+	keys, err := p.listKVKeys(kvApprovalPrefix)
+	if err != nil {
+		p.API.LogError("Unable to list approval requests", "err", err)
+		return
+	}
+
+	now := model.GetMillis()
+	for _, key := range keys {
+		var request *approvalRequest
+		if err := p.client.KV.Get(key, &request); err != nil || request == nil || request.ExpireAt >= now {
+			continue
+		}
+
+		expired, err := p.takeApprovalRequest(request.ID)
+		if err != nil {
+			p.API.LogError("Unable to expire approval request", "key", key, "err", err)
+			continue
+		}
+
+		if expired == nil {
+			continue // reviewed meanwhile
+		}
+
+		p.updateApprovalPost(expired, "This request has expired.")
+		p.sendEphemeralPost(expired.UserID, expired.ChannelID, fmt.Sprintf(
+			"Nobody approved your housecleaning of %d posts in time, so nothing has been deleted.", expired.NumPosts,
+		))
+	}
+}
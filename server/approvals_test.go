@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiresApproval(t *testing.T) {
+	for name, tc := range map[string]struct {
+		posts    []*model.Post
+		filters  postFilters
+		expected bool
+	}{
+		"below the threshold": {
+			posts:    []*model.Post{{Id: "post2", UserId: "user"}, {Id: "post1", UserId: "user"}},
+			expected: false,
+		},
+		"replies deleted along with their thread": {
+			posts: []*model.Post{
+				{Id: "reply2", UserId: "other", RootId: "root"},
+				{Id: "reply1", UserId: "other", RootId: "root"},
+				{Id: "root", UserId: "user", ReplyCount: 2},
+			},
+			expected: true,
+		},
+		"root kept for the replies not selected": {
+			posts: []*model.Post{
+				{Id: "reply2", UserId: "user", RootId: "root"},
+				{Id: "reply1", UserId: "other", RootId: "root"},
+				{Id: "root", UserId: "user", ReplyCount: 2},
+				{Id: "post", UserId: "user"},
+			},
+			filters:  postFilters{UserIDs: []string{"user"}},
+			expected: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			postList := model.NewPostList()
+			for _, post := range tc.posts {
+				postList.AddPost(post)
+				postList.AddOrder(post.Id)
+			}
+
+			api := &plugintest.API{}
+			api.On("KVGet", getProtectedAuthorsKey("channel")).Return(nil, nil)
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+			p.setConfiguration(&configuration{ApprovalThreshold: 2, ApproversChannelID: "approvers"})
+
+			options := &deletionOptions{channelID: "channel", userID: "user", permDeleteOthersPosts: true, filters: tc.filters}
+			assert.Equal(t, tc.expected, p.requiresApproval(postList, options))
+		})
+	}
+}
+
+// isApprovalTaken matches the atomic removal of the approval request saved as data
+func isApprovalTaken(data []byte) (any, any) {
+	return mock.MatchedBy(func(value []byte) bool { return value == nil }),
+		mock.MatchedBy(func(options model.PluginKVSetOptions) bool {
+			return options.Atomic && bytes.Equal(options.OldValue, data)
+		})
+}
+
+func TestTakeApprovalRequest(t *testing.T) {
+	data, err := json.Marshal(&approvalRequest{ID: "request", UserID: "user", ChannelID: "channel"})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		kvValue  []byte
+		removed  bool
+		expected bool
+	}{
+		"pending request": {
+			kvValue:  data,
+			removed:  true,
+			expected: true,
+		},
+		"taken meanwhile": {
+			kvValue:  data,
+			removed:  false,
+			expected: false,
+		},
+		"already taken": {
+			kvValue:  nil,
+			expected: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("KVGet", getApprovalKey("request")).Return(tc.kvValue, nil).Once()
+			if tc.kvValue != nil {
+				value, options := isApprovalTaken(tc.kvValue)
+				api.On("KVSetWithOptions", getApprovalKey("request"), value, options).Return(tc.removed, nil).Once()
+			}
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+
+			request, err := p.takeApprovalRequest("request")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, request != nil)
+			if tc.expected {
+				assert.Equal(t, "user", request.UserID)
+			}
+		})
+	}
+}
+
+func TestExpireApprovalRequests(t *testing.T) {
+	now := model.GetMillis()
+	expired, err := json.Marshal(&approvalRequest{ID: "expired", UserID: "user", ChannelID: "channel", NumPosts: 12, ApprovalPostID: "approvalPost", ExpireAt: now - 1000})
+	require.NoError(t, err)
+	pending, err := json.Marshal(&approvalRequest{ID: "pending", UserID: "user", ChannelID: "channel", ExpireAt: now + 60000})
+	require.NoError(t, err)
+
+	api := &plugintest.API{}
+	api.On("KVList", 0, kvListPerPage).Return([]string{getApprovalKey("expired"), getApprovalKey("pending"), getQuotaKey("user")}, nil).Once()
+	api.On("KVGet", getApprovalKey("expired")).Return(expired, nil)
+	api.On("KVGet", getApprovalKey("pending")).Return(pending, nil)
+	value, options := isApprovalTaken(expired)
+	api.On("KVSetWithOptions", getApprovalKey("expired"), value, options).Return(true, nil).Once()
+	api.On("GetPost", "approvalPost").Return(&model.Post{Id: "approvalPost", Message: "Request"}, nil).Once()
+	api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.Id == "approvalPost" && strings.HasSuffix(post.Message, "This request has expired.")
+	})).Return(&model.Post{}, nil).Once()
+	api.On("SendEphemeralPost", "user", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "channel" && strings.HasPrefix(post.Message, "Nobody approved your housecleaning of 12 posts")
+	})).Return(&model.Post{}).Once()
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.client = pluginapi.NewClient(api, nil)
+
+	p.expireApprovalRequests()
+	api.AssertNotCalled(t, "KVSetWithOptions", getApprovalKey("pending"), mock.Anything, mock.Anything)
+	api.AssertNotCalled(t, "KVGet", getQuotaKey("user"))
+}
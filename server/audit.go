@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	// auditRetention is for how long the audit records are kept in the KV store
+	auditRetention = 90 * 24 * time.Hour
+
+	// maxHistoryEntries is the number of audit records listed in the history
+	maxHistoryEntries = 1000
+)
+
+// auditRecord describes a housecleaning, once done
+type auditRecord struct {
+	ID                  string   `json:"id"`
+	UserID              string   `json:"user_id"`
+	ChannelID           string   `json:"channel_id"`
+	Command             string   `json:"command"`
+	Selectors           []string `json:"selectors"`
+	Deleted             int      `json:"deleted"`
+	RootPosts           int      `json:"root_posts"`
+	Replies             int      `json:"replies"`
+	CascadeReplies      int      `json:"cascade_replies"`
+	SkippedPinned       int      `json:"skipped_pinned"`
+	SkippedNotPermitted int      `json:"skipped_not_permitted"`
+	SkippedProtected    int      `json:"skipped_protected"`
+	SkippedThreads      int      `json:"skipped_threads"`
+	Errors              int      `json:"errors"`
+	ArchiveLink         string   `json:"archive_link,omitempty"`
+	CreateAt            int64    `json:"create_at"`
+}
+
+func getAuditKey(recordID string) string {
+	return kvAuditPrefix + recordID
+}
+
+// getSelectorsDescription describes the criteria of options used to select the posts
+func (p *Plugin) getSelectorsDescription(options *deletionOptions) []string {
+	location := p.getUserLocation(options.userID)
+	selectors := []string{}
+
+	if options.rootID != "" {
+		selectors = append(selectors, "thread "+options.rootID)
+		if options.optIncludeRoot {
+			selectors = append(selectors, "root post included")
+		}
+	}
+	if options.numPost > 0 {
+		selectors = append(selectors, fmt.Sprintf("last %d posts", options.numPost))
+	}
+	if options.sinceTime > 0 {
+		selectors = append(selectors, "since "+formatTime(options.sinceTime, location))
+	}
+	if options.untilTime > 0 {
+		selectors = append(selectors, "until "+formatTime(options.untilTime, location))
+	}
+	if options.fromPostID != "" {
+		selectors = append(selectors, "from post "+options.fromPostID)
+	}
+	if options.moveChannelID != "" {
+		selectors = append(selectors, "moved to ~"+p.getChannelName(options.moveChannelID))
+	}
+
+	if len(options.filters.UserIDs) > 0 {
+		selectors = append(selectors, "users "+p.getUserMentions(options.filters.UserIDs))
+	}
+	if len(options.filters.NotUserIDs) > 0 {
+		selectors = append(selectors, "not users "+p.getUserMentions(options.filters.NotUserIDs))
+	}
+	if options.filters.Match != "" {
+		selectors = append(selectors, fmt.Sprintf("matching `%s`", options.filters.Match))
+	}
+	if options.filters.Contains != "" {
+		selectors = append(selectors, fmt.Sprintf("containing `%s`", options.filters.Contains))
+	}
+	if len(options.filters.Types) > 0 {
+		selectors = append(selectors, "types "+strings.Join(options.filters.Types, ", "))
+	}
+	if options.optDeletePinnedPosts {
+		selectors = append(selectors, "pinned posts included")
+	}
+
+	return selectors
+}
+
+// getUserMentions returns the @mentions of the users, separated by commas
+func (p *Plugin) getUserMentions(userIDs []string) string {
+	mentions := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		mentions = append(mentions, p.getUserMention(userID))
+	}
+
+	return strings.Join(mentions, ", ")
+}
+
+// audit keeps a record of the housecleaning in the KV store, and posts a summary in the audit channel if any
+func (p *Plugin) audit(options *deletionOptions, result *deletePostResult, archiveLink string) {
+	record := &auditRecord{
+		ID:                  model.NewId(),
+		UserID:              options.userID,
+		ChannelID:           options.channelID,
+		Command:             options.command,
+		Selectors:           p.getSelectorsDescription(options),
+		Deleted:             result.numPostsDeleted,
+		RootPosts:           result.numRootPostsDeleted,
+		Replies:             result.numRepliesDeleted,
+		CascadeReplies:      result.numCascadeRepliesDeleted,
+		SkippedPinned:       result.pinnedPostErrors,
+		SkippedNotPermitted: result.notPermittedErrors,
+		SkippedProtected:    result.protectedPostErrors,
+		SkippedThreads:      len(result.keptRootPosts),
+		Errors:              result.technicalErrors,
+		ArchiveLink:         archiveLink,
+		CreateAt:            model.GetMillis(),
+	}
+
+	if _, err := p.client.KV.Set(getAuditKey(record.ID), record, pluginapi.SetExpiry(auditRetention)); err != nil {
+		p.API.LogError("Unable to save audit record", "err", err)
+	} else if err := p.addAuditRecordToHistory(record); err != nil {
+		p.API.LogError("Unable to add audit record to the history", "err", err)
+	}
+
+	auditChannelID := p.getConfiguration().AuditChannelID
+	if auditChannelID == "" {
+		return
+	}
+
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: auditChannelID,
+		Message:   p.getAuditMessage(record),
+	}); appErr != nil {
+		p.API.LogError("Unable to post audit summary", "appErr", appErr)
+	}
+}
+
+// getAuditMessage summarizes the audit record for the audit channel
+func (p *Plugin) getAuditMessage(record *auditRecord) string {
+	message := fmt.Sprintf(
+		"#### Housecleaning by %s in ~%s\n**Command:** `%s`\n",
+		p.getUserMention(record.UserID), p.getChannelName(record.ChannelID), record.Command,
+	)
+
+	if len(record.Selectors) > 0 {
+		message += "**Selectors:** " + strings.Join(record.Selectors, ", ") + "\n"
+	}
+
+	message += "\n| Deleted | Root posts | Replies | Thread replies | Skipped (pinned) | Skipped (not permitted) | Skipped (protected author) | Skipped (thread kept) | Errors |\n" +
+		"|--:|--:|--:|--:|--:|--:|--:|--:|--:|\n" +
+		fmt.Sprintf(
+			"| %d | %d | %d | %d | %d | %d | %d | %d | %d |\n",
+			record.Deleted, record.RootPosts, record.Replies, record.CascadeReplies,
+			record.SkippedPinned, record.SkippedNotPermitted, record.SkippedProtected, record.SkippedThreads, record.Errors,
+		)
+
+	if record.ArchiveLink != "" {
+		message += fmt.Sprintf("\nThe deleted posts have been [archived](%s).", record.ArchiveLink)
+	}
+
+	return strings.TrimSuffix(message, "\n")
+}
+
+// addAuditRecordToHistory records the audit record in the history, keeping only the most recent ones
+func (p *Plugin) addAuditRecordToHistory(record *auditRecord) error {
+	return p.client.KV.SetAtomicWithRetries(kvHistoryKey, func(oldValue []byte) (any, error) {
+		var recordIDs []string
+		if len(oldValue) > 0 {
+			if err := json.Unmarshal(oldValue, &recordIDs); err != nil {
+				return nil, err
+			}
+		}
+
+		recordIDs = append([]string{record.ID}, recordIDs...)
+		if len(recordIDs) > maxHistoryEntries {
+			recordIDs = recordIDs[:maxHistoryEntries]
+		}
+
+		return recordIDs, nil
+	})
+}
+
+// getHistory returns at most limit audit records, from the most recent one.
+// If channelID is empty, the records of every channel are returned
+func (p *Plugin) getHistory(channelID string, userIDs []string, limit int) ([]*auditRecord, error) {
+	var recordIDs []string
+	if err := p.client.KV.Get(kvHistoryKey, &recordIDs); err != nil {
+		return nil, err
+	}
+
+	records := []*auditRecord{}
+	for _, recordID := range recordIDs {
+		var record *auditRecord
+		if err := p.client.KV.Get(getAuditKey(recordID), &record); err != nil {
+			return nil, err
+		}
+
+		if record == nil || !record.matches(channelID, userIDs) { // the record may have expired
+			continue
+		}
+
+		records = append(records, record)
+		if len(records) >= limit {
+			break
+		}
+	}
+
+	return records, nil
+}
+
+// matches tells if the record is about the channel, if any, and by one of the users, if any
+func (record *auditRecord) matches(channelID string, userIDs []string) bool {
+	if channelID != "" && record.ChannelID != channelID {
+		return false
+	}
+
+	return len(userIDs) == 0 || contains(userIDs, record.UserID)
+}
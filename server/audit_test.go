@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAuditRecordMatches(t *testing.T) {
+	record := &auditRecord{UserID: "user1", ChannelID: "channel1"}
+
+	for name, tc := range map[string]struct {
+		channelID string
+		userIDs   []string
+		expected  bool
+	}{
+		"all channels, all users": {channelID: "", userIDs: nil, expected: true},
+		"channel":                 {channelID: "channel1", userIDs: nil, expected: true},
+		"other channel":           {channelID: "channel2", userIDs: nil, expected: false},
+		"user":                    {channelID: "", userIDs: []string{"user1"}, expected: true},
+		"other user":              {channelID: "", userIDs: []string{"user2"}, expected: false},
+		"one of the users":        {channelID: "channel1", userIDs: []string{"user2", "user1"}, expected: true},
+		"user in other channel":   {channelID: "channel2", userIDs: []string{"user1"}, expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if matches := record.matches(tc.channelID, tc.userIDs); matches != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, matches)
+			}
+		})
+	}
+}
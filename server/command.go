@@ -17,21 +17,56 @@ const (
 
 	argDeletePinnedPost = "delete-pinned-posts"
 	argNoConfirm        = "confirm"
+	argUser             = "user"
+	argNotUser          = "not-user"
+	argDryRun           = "dry-run"
+	argArchive          = "archive"
+	argMatch            = "match"
+	argContains         = "contains"
+	argType             = "type"
+	argIncludeRoot      = "include-root"
 )
 
+// Subcommands deleting posts, which can't be used in the protected channels.
+// The schedules, retention policies and TTL can still be managed there, but are not applied
+var deletingTriggers = []string{lastTrigger, sinceTrigger, betweenTrigger, fromTrigger, moveTrigger, threadTrigger}
+
 func (p *Plugin) getCommand() *model.Command {
 	const (
-		command         = "broom"
-		commandHint     = "[subcommand]"
-		commandHelpText = "Clean the channel by removing posts. Available commands: " + lastTrigger + ", " + helpTrigger
+		command     = "broom"
+		commandHint = "[subcommand]"
 	)
 
+	commandHelpText := "Clean the channel by removing posts. Available commands: " + lastTrigger + ", " + sinceTrigger + ", " + betweenTrigger + ", " + fromTrigger + ", " + moveTrigger + ", " + threadTrigger + ", " + undoTrigger + ", " + statusTrigger + ", " + cancelTrigger + ", " + scheduleTrigger + ", " + retentionTrigger + ", " + ttlTrigger + ", " + protectTrigger + ", " + unprotectTrigger + ", " + historyTrigger + ", " + helpTrigger
+
+	// The autocomplete can only be restricted to system admins, the other roles are checked when running the command
+	allowedRoles := p.getConfiguration().getAllowedRoles()
+	if allowedRoles == allowedRolesTeamAdmin || allowedRoles == allowedRolesChannelAdmin {
+		commandHelpText += ". Only for " + getAllowedRolesDisplayName(allowedRoles)
+	}
+
 	cmdAutocompleteData := model.NewAutocompleteData(command, commandHint, commandHelpText)
-	if p.getConfiguration().RestrictToSysadmins {
-		cmdAutocompleteData.RoleID = "system_admin"
+	if allowedRoles == allowedRolesSystemAdmin {
+		cmdAutocompleteData.RoleID = model.SystemAdminRoleId
 	}
 
 	cmdAutocompleteData.AddCommand(getLastAutocompleteData(p.getConfiguration()))
+	cmdAutocompleteData.AddCommand(getSinceAutocompleteData(p.getConfiguration()))
+	cmdAutocompleteData.AddCommand(getBetweenAutocompleteData(p.getConfiguration()))
+	cmdAutocompleteData.AddCommand(getFromAutocompleteData(p.getConfiguration()))
+	cmdAutocompleteData.AddCommand(getMoveAutocompleteData(p.getConfiguration()))
+	cmdAutocompleteData.AddCommand(getThreadAutocompleteData(p.getConfiguration()))
+	if p.getConfiguration().UndoGracePeriodMinutes > 0 {
+		cmdAutocompleteData.AddCommand(getUndoAutocompleteData())
+	}
+	cmdAutocompleteData.AddCommand(getStatusAutocompleteData())
+	cmdAutocompleteData.AddCommand(getCancelAutocompleteData())
+	cmdAutocompleteData.AddCommand(getScheduleAutocompleteData())
+	cmdAutocompleteData.AddCommand(getRetentionAutocompleteData())
+	cmdAutocompleteData.AddCommand(getTTLAutocompleteData())
+	cmdAutocompleteData.AddCommand(getProtectAutocompleteData())
+	cmdAutocompleteData.AddCommand(getUnprotectAutocompleteData())
+	cmdAutocompleteData.AddCommand(getHistoryAutocompleteData())
 	cmdAutocompleteData.AddCommand(model.NewAutocompleteData(helpTrigger, "", "Learn how to broom"))
 
 	return &model.Command{
@@ -61,9 +96,16 @@ func (p *Plugin) getAutocompleteIconData() string {
 }
 
 func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
-	// Respond "no trigger found" if the user is not authorized
-	if p.getConfiguration().RestrictToSysadmins && !isSysadmin(p, args.UserId) {
-		return nil, nil
+	if !isAllowedToBroom(p, args.UserId, args.TeamId, args.ChannelId) {
+		allowedRoles := p.getConfiguration().getAllowedRoles()
+		if allowedRoles == allowedRolesSystemAdmin {
+			// Respond "no trigger found", as the command is hidden to the other users
+			return nil, nil
+		}
+
+		return p.respondEphemeralResponse(args, fmt.Sprintf(
+			"Sorry, only the %s can use `/broom` here.", getAllowedRolesDisplayName(allowedRoles),
+		)), nil
 	}
 
 	subcommand, options, userErr := p.parseAndCheckCommandArgs(args)
@@ -71,10 +113,56 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		return p.respondEphemeralResponse(args, userErr.Error()), nil
 	}
 
+	if contains(deletingTriggers, subcommand) && p.isChannelProtected(args.ChannelId) {
+		return p.respondEphemeralResponse(args, messageChannelProtected), nil
+	}
+
 	switch subcommand {
 	case lastTrigger:
 		return p.executeLast(options)
 
+	case sinceTrigger:
+		return p.executeSince(options)
+
+	case betweenTrigger:
+		return p.executeBetween(options)
+
+	case fromTrigger:
+		return p.executeFrom(options)
+
+	case moveTrigger:
+		return p.executeMove(options)
+
+	case threadTrigger:
+		return p.executeThread(options)
+
+	case undoTrigger:
+		return p.executeUndo(options)
+
+	case statusTrigger:
+		return p.executeStatus(options)
+
+	case cancelTrigger:
+		return p.executeCancel(options)
+
+	case scheduleTrigger:
+		return p.executeSchedule(args, options)
+
+	case retentionTrigger:
+		return p.executeRetention(options)
+
+	case ttlTrigger:
+		return p.executeTTL(options)
+
+	case protectTrigger:
+		return p.executeProtect(options)
+
+	case unprotectTrigger:
+		return p.executeUnprotect(options)
+
+	case historyTrigger:
+		return p.executeHistory(options)
+
 	case helpTrigger:
 		fallthrough
 	default:
@@ -87,10 +175,39 @@ func getHelp(conf *configuration) string {
 		"Easily clean the current channel with this magic broom.\n" +
 		"\n" +
 		" * `/broom " + lastTrigger + " " + lastHint + "` " + lastHelpText + "\n" +
+		" * `/broom " + sinceTrigger + " " + sinceHint + "` " + sinceHelpText + "\n" +
+		" * `/broom " + betweenTrigger + " " + betweenHint + "` " + betweenHelpText + "\n" +
+		" * `/broom " + fromTrigger + " " + fromHint + "` " + fromHelpText + "\n" +
+		" * `/broom " + moveTrigger + " " + moveHint + "` " + moveHelpText + "\n" +
+		" * `/broom " + threadTrigger + " " + threadHint + "` " + threadHelpText + ". Use `--" + argIncludeRoot + " true` to delete the whole thread\n"
+
+	if conf.UndoGracePeriodMinutes > 0 {
+		helpStr += " * `/broom " + undoTrigger + "` " + undoHelpText + "\n"
+	}
 
-		"\n" +
+	helpStr += " * `/broom " + statusTrigger + "` " + statusHelpText + "\n" +
+		" * `/broom " + cancelTrigger + " " + cancelHint + "` " + cancelHelpText + "\n" +
+		" * `/broom " + scheduleTrigger + " " + scheduleAddTrigger + " " + scheduleAddHint + "` " + scheduleAddHelpText + "\n" +
+		" * `/broom " + scheduleTrigger + " " + scheduleListTrigger + "` " + scheduleListHelpText + "\n" +
+		" * `/broom " + scheduleTrigger + " " + scheduleRemoveTrigger + " " + scheduleRemoveHint + "` " + scheduleRemoveHelpText + "\n" +
+		" * `/broom " + retentionTrigger + " " + retentionSetTrigger + " " + retentionSetHint + "` " + retentionSetHelpText + "\n" +
+		" * `/broom " + retentionTrigger + " " + retentionShowTrigger + "` " + retentionShowHelpText + "\n" +
+		" * `/broom " + retentionTrigger + " " + retentionRemoveTrigger + "` " + retentionRemoveHelpText + "\n" +
+		" * `/broom " + ttlTrigger + " " + ttlHint + "` " + ttlHelpText + "\n" +
+		" * `/broom " + protectTrigger + " [--" + argUser + " @username]` " + protectHelpText + "\n" +
+		" * `/broom " + unprotectTrigger + " [--" + argUser + " @username]` " + unprotectHelpText + "\n" +
+		" * `/broom " + historyTrigger + " [--" + argChannel + " ~channel|all] [--" + argUser + " @username] [--" + argLimit + " number]` " + historyHelpText + "\n"
+
+	helpStr += "\n" +
 		"### Global arguments :\n" +
-		" * `--" + argDeletePinnedPost + "` Also delete pinned post (disabled by default)\n"
+		" * `--" + argDeletePinnedPost + "` Also delete pinned post (disabled by default)\n" +
+		" * `--" + argUser + " @username` Only delete the posts of this user (can be repeated)\n" +
+		" * `--" + argNotUser + " @username` Do not delete the posts of this user (can be repeated)\n" +
+		" * `--" + argDryRun + "` Do not delete anything, only show what would be deleted\n" +
+		" * `--" + argArchive + " json|csv|markdown` Archive the posts in a file before deleting them\n" +
+		" * `--" + argMatch + " \"regex\"` Only delete the posts matching this regular expression\n" +
+		" * `--" + argContains + " \"text\"` Only delete the posts containing this text, ignoring case\n" +
+		" * `--" + argType + " system|join-leave|webhook|bot|user` Only delete the posts of this type (can be repeated)\n"
 
 	if conf.AskConfirm == askConfirmOptional {
 		helpStr += " * `--" + argNoConfirm + "` Do not show confirmation dialog\n"
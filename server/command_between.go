@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	betweenTrigger  = "between"
+	betweenHint     = "[start] [end]"
+	betweenHelpText = "Delete the posts of the channel posted between [start] and [end], each being a duration like `2h` or a date like `2006-01-02 15:04`, the end included"
+)
+
+func getBetweenAutocompleteData(conf *configuration) *model.AutocompleteData {
+	between := model.NewAutocompleteData(betweenTrigger, betweenHint, betweenHelpText)
+	between.AddTextArgument("The beginning and the end of the time window, like `2h 1h` or `14:02 14:17`", betweenHint, "")
+	addAllNamedTextArgumentsToCmd(between, conf.AskConfirm == askConfirmOptional)
+
+	return between
+}
+
+// parseBetweenArgs checks the [start] and [end] arguments and stores them in options
+func (p *Plugin) parseBetweenArgs(args *model.CommandArgs, positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) < 2 {
+		return errors.Errorf("Please specify the beginning and the end of the time window: `/broom %s %s`", betweenTrigger, betweenHint)
+	}
+
+	start, end, err := parseTimeWindow(positionalArgs, time.Now().In(p.getUserLocation(args.UserId)))
+	if err != nil {
+		return err
+	}
+
+	options.sinceTime = start.UnixMilli()
+	options.untilTime = end.UnixMilli()
+	return nil
+}
+
+// parseTimeWindow parses the beginning and the end of a time window, given in args.
+// A date may contain a space, so every way to split args in two is tried, and only one of them must be valid.
+// The end is included up to its precision: `10:00` ends at 10:00:59.999
+func parseTimeWindow(args []string, now time.Time) (time.Time, time.Time, error) {
+	var start, end *timeArg
+	var lastErr error
+	for i := 1; i < len(args); i++ {
+		splitStart, err := parseTimeArgDetails(strings.Join(args[:i], " "), now)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		splitEnd, err := parseTimeArgDetails(strings.Join(args[i:], " "), now)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if start != nil {
+			return time.Time{}, time.Time{}, errors.Errorf(
+				"The time window `%s` is ambiguous, please put the dates between quotes, like `\"2006-01-02 15:04\"`",
+				strings.Join(args, " "),
+			)
+		}
+		start, end = splitStart, splitEnd
+	}
+
+	if start == nil {
+		return time.Time{}, time.Time{}, lastErr
+	}
+
+	// In `2026-10-01 09:00 10:00`, 10:00 is today, not on the same day as the beginning
+	if end.isTimeOfDay && !start.isDuration && !start.isTimeOfDay {
+		return time.Time{}, time.Time{}, errors.Errorf(
+			"The beginning of the time window is a date, please give the date of its end too, like `2006-01-02 15:04`",
+		)
+	}
+
+	if start.time.After(end.getEnd()) {
+		return time.Time{}, time.Time{}, errors.Errorf("The beginning of the time window should be before its end")
+	}
+
+	return start.time, end.getEnd(), nil
+}
+
+func (p *Plugin) executeBetween(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if p.shouldConfirmDeletion(options) {
+		p.sendDialogDeleteBetween(options)
+	} else {
+		p.deleteBetweenPostsInChannel(options)
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+func (p *Plugin) sendDialogDeleteBetween(options *deletionOptions) {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	location := p.getUserLocation(options.userID)
+
+	introduction := ""
+	if options.filters.hasTextFilter() {
+		postList, appErr := p.getPostsBetween(options.channelID, options.sinceTime, options.untilTime)
+		if appErr != nil {
+			p.API.LogError("Unable to retrieve posts", "appErr", appErr)
+			p.sendEphemeralPost(options.userID, options.channelID, "Error when retrieving the posts to delete")
+			return
+		}
+		introduction = options.filters.getMatchedCountText(getRelevantPostList(postList))
+	}
+
+	dialog := &model.OpenDialogRequest{
+		TriggerId: options.triggerID,
+		URL:       fmt.Sprintf("%s/plugins/%s%s", *siteURL, manifest.Id, routeDialogDeleteBetween),
+		Dialog: model.Dialog{
+			CallbackId: "confirmPostDeletion",
+			Title: fmt.Sprintf(
+				"Do you want to delete all the posts between %s and %s in this channel?",
+				formatTime(options.sinceTime, location), formatTime(options.untilTime, location),
+			),
+			IntroductionText: introduction,
+			SubmitLabel:      "Confirm",
+			NotifyOnCancel:   false,
+			State:            options.getDialogState(fmt.Sprintf("%d %d", options.sinceTime, options.untilTime)),
+			Elements: []model.DialogElement{
+				{
+					Type:        "bool",
+					Name:        "deletePinnedPosts",
+					DisplayName: "Delete pinned posts?",
+					HelpText:    "",
+					Default:     strconv.FormatBool(options.optDeletePinnedPosts),
+					Optional:    true,
+				},
+			},
+		},
+	}
+
+	if err := p.API.OpenInteractiveDialog(*dialog); err != nil {
+		p.API.LogError("Failed to open Interactive Dialog", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Failed to open Interactive Dialog")
+	}
+}
+
+func (p *Plugin) deleteBetweenPostsInChannel(options *deletionOptions) {
+	hasPermissionToDeletePost := canDeletePost(p, options.userID, options.channelID)
+	if !hasPermissionToDeletePost {
+		p.sendEphemeralPost(options.userID, options.channelID, "Sorry, you are not permitted to delete posts")
+		return
+	}
+
+	postList, appErr := p.getPostsBetween(options.channelID, options.sinceTime, options.untilTime)
+	if appErr != nil {
+		p.API.LogError("Unable to retrieve posts", "appErr", appErr)
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when deleting posts")
+		return
+	}
+
+	p.deletePostsAndReport(postList, options)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, location)
+
+	for name, tc := range map[string]struct {
+		args          []string
+		expectedStart time.Time
+		expectedEnd   time.Time
+		expectedError bool
+	}{
+		"durations": {
+			args:          []string{"3h", "2h"},
+			expectedStart: now.Add(-3 * time.Hour),
+			expectedEnd:   now.Add(-2 * time.Hour),
+		},
+		"times of day": {
+			args:          []string{"14:02", "14:17"},
+			expectedStart: time.Date(2026, 10, 18, 14, 2, 0, 0, location),
+			expectedEnd:   time.Date(2026, 10, 18, 14, 17, 59, int(999*time.Millisecond), location),
+		},
+		"same minute": {
+			args:          []string{"14:02", "14:02"},
+			expectedStart: time.Date(2026, 10, 18, 14, 2, 0, 0, location),
+			expectedEnd:   time.Date(2026, 10, 18, 14, 2, 59, int(999*time.Millisecond), location),
+		},
+		"dates with a space": {
+			args:          []string{"2026-10-01", "09:00", "2026-10-01", "10:00"},
+			expectedStart: time.Date(2026, 10, 1, 9, 0, 0, 0, location),
+			expectedEnd:   time.Date(2026, 10, 1, 10, 0, 59, int(999*time.Millisecond), location),
+		},
+		"quoted dates": {
+			args:          []string{"2026-10-01 09:00", "2026-10-01 10:00:30"},
+			expectedStart: time.Date(2026, 10, 1, 9, 0, 0, 0, location),
+			expectedEnd:   time.Date(2026, 10, 1, 10, 0, 30, int(999*time.Millisecond), location),
+		},
+		"whole days": {
+			args:          []string{"2026-10-01", "2026-10-02"},
+			expectedStart: time.Date(2026, 10, 1, 0, 0, 0, 0, location),
+			expectedEnd:   time.Date(2026, 10, 2, 23, 59, 59, int(999*time.Millisecond), location),
+		},
+		"duration and time of day": {
+			args:          []string{"1d", "09:00"},
+			expectedStart: now.Add(-24 * time.Hour),
+			expectedEnd:   time.Date(2026, 10, 18, 9, 0, 59, int(999*time.Millisecond), location),
+		},
+		"time of day after a date":      {args: []string{"2026-10-01", "09:00", "10:00"}, expectedError: true},
+		"date alone":                    {args: []string{"2026-10-01", "09:00"}, expectedError: true},
+		"beginning after the end":       {args: []string{"2h", "3h"}, expectedError: true},
+		"invalid end":                   {args: []string{"2h", "yesterday"}, expectedError: true},
+		"end in the future":             {args: []string{"2h", "16:00"}, expectedError: true},
+		"three durations":               {args: []string{"3h", "2h", "1h"}, expectedError: true},
+		"date and time in wrong places": {args: []string{"09:00", "2026-10-01", "10:00"}, expectedError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			start, end, err := parseTimeWindow(tc.args, now)
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, got %v - %v", start, end)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !start.Equal(tc.expectedStart) {
+				t.Errorf("expected start %v, got %v", tc.expectedStart, start)
+			}
+			if !end.Equal(tc.expectedEnd) {
+				t.Errorf("expected end %v, got %v", tc.expectedEnd, end)
+			}
+		})
+	}
+}
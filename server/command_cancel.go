@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	cancelTrigger  = "cancel"
+	cancelHint     = "[job-id]"
+	cancelHelpText = "Stop a running housecleaning, by default your last one in the channel"
+)
+
+func getCancelAutocompleteData() *model.AutocompleteData {
+	cancel := model.NewAutocompleteData(cancelTrigger, cancelHint, cancelHelpText)
+	cancel.AddTextArgument("The ID of the job to cancel, as shown by /broom "+statusTrigger, cancelHint, "")
+
+	return cancel
+}
+
+// parseCancelArgs checks the optional [job-id] argument and stores it in options
+func (p *Plugin) parseCancelArgs(positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) > 1 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[1])
+	}
+
+	if len(positionalArgs) == 1 {
+		if !model.IsValidId(positionalArgs[0]) {
+			return errors.Errorf("`%s` is not a valid job ID", positionalArgs[0])
+		}
+		options.jobID = positionalArgs[0]
+	}
+
+	return nil
+}
+
+func (p *Plugin) executeCancel(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	job, err := p.getJobToCancel(options)
+	if err != nil {
+		p.API.LogError("Unable to get the job to cancel", "err", err)
+		return p.respondEphemeralPost(options, "Error when cancelling the housecleaning"), nil
+	}
+
+	if job == nil {
+		return p.respondEphemeralPost(options, "There is no running housecleaning to cancel."), nil
+	}
+
+	if job.UserID != options.userID && !isSysadmin(p, options.userID) {
+		return p.respondEphemeralPost(options, "Sorry, you can only cancel your own housecleanings"), nil
+	}
+
+	if job.Status != jobStatusRunning {
+		return p.respondEphemeralPost(options, fmt.Sprintf("The housecleaning `%s` is already %s.", job.ID, job.Status)), nil
+	}
+
+	if err := p.requestJobCancellation(job.ID); err != nil {
+		p.API.LogError("Unable to cancel job", "JobID", job.ID, "err", err)
+		return p.respondEphemeralPost(options, "Error when cancelling the housecleaning"), nil
+	}
+
+	return p.respondEphemeralPost(options, fmt.Sprintf(
+		"The housecleaning `%s` will stop after the post being deleted. It has already processed %d of %d posts.",
+		job.ID, job.Processed, job.Total,
+	)), nil
+}
+
+// getJobToCancel returns the job given in options, or the last running job of the user in the channel
+func (p *Plugin) getJobToCancel(options *deletionOptions) (*deletionJob, error) {
+	if options.jobID != "" {
+		return p.getJob(options.jobID)
+	}
+
+	jobs, err := p.getRecentJobs(options.userID, options.channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		if job.Status == jobStatusRunning {
+			return job, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	fromTrigger  = "from"
+	fromHint     = "[post-link|post-id]"
+	fromHelpText = "Delete the post [post-link|post-id] and all the posts of the channel posted after it"
+)
+
+var permalinkRegexp = regexp.MustCompile(`/pl/([a-z0-9]{26})/?$`)
+
+func getFromAutocompleteData(conf *configuration) *model.AutocompleteData {
+	from := model.NewAutocompleteData(fromTrigger, fromHint, fromHelpText)
+	from.AddTextArgument("The permalink or the ID of the first post to delete", fromHint, "")
+	addAllNamedTextArgumentsToCmd(from, conf.AskConfirm == askConfirmOptional)
+
+	return from
+}
+
+// parsePostIDArg extracts the post ID from a permalink or a raw post ID
+func parsePostIDArg(value string) (string, error) {
+	if match := permalinkRegexp.FindStringSubmatch(value); match != nil {
+		return match[1], nil
+	}
+
+	if model.IsValidId(value) {
+		return value, nil
+	}
+
+	return "", errors.Errorf("`%s` is neither a post link nor a post ID", value)
+}
+
+// parseFromArgs checks the [post-link|post-id] argument and stores it in options
+func (p *Plugin) parseFromArgs(args *model.CommandArgs, positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) == 0 {
+		return errors.Errorf("Please specify the first post to delete: `/broom %s %s`", fromTrigger, fromHint)
+	}
+
+	if len(positionalArgs) > 1 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[1])
+	}
+
+	postID, err := parsePostIDArg(positionalArgs[0])
+	if err != nil {
+		return err
+	}
+
+	if _, userErr := p.getFromPost(postID, args.ChannelId); userErr != nil {
+		return userErr
+	}
+
+	options.fromPostID = postID
+	return nil
+}
+
+// getFromPost retrieves the post the deletion starts at, and checks it belongs to the channel
+func (p *Plugin) getFromPost(postID string, channelID string) (*model.Post, userError) {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		if appErr.StatusCode != http.StatusNotFound {
+			p.API.LogError("Unable to get post", "PostID", postID, "appErr", appErr)
+		}
+		return nil, errors.Errorf("Unable to find the post `%s`", postID)
+	}
+
+	if post.ChannelId != channelID {
+		return nil, errors.Errorf("The post `%s` does not belong to this channel", postID)
+	}
+
+	return post, nil
+}
+
+// getPostsFrom returns the post fromPostID and all the posts of the channel posted after it
+func (p *Plugin) getPostsFrom(channelID string, fromPostID string) (*model.PostList, error) {
+	fromPost, userErr := p.getFromPost(fromPostID, channelID)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	postList, appErr := p.getPostsSince(channelID, fromPost.CreateAt)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return postList, nil
+}
+
+func (p *Plugin) executeFrom(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if p.shouldConfirmDeletion(options) {
+		p.sendDialogDeleteFrom(options)
+	} else {
+		p.deleteFromPostsInChannel(options)
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+func (p *Plugin) sendDialogDeleteFrom(options *deletionOptions) {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+
+	postList, err := p.getPostsFrom(options.channelID, options.fromPostID)
+	if err != nil {
+		p.API.LogError("Unable to retrieve posts", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when retrieving the posts to delete")
+		return
+	}
+
+	postListToDelete := p.selectPostsToDelete(getRelevantPostList(postList), options, new(deletePostResult))
+	numPost := len(postListToDelete.Order)
+	if numPost == 0 {
+		p.sendEphemeralPost(options.userID, options.channelID, "There are no posts to delete.")
+		return
+	}
+
+	firstPost := postListToDelete.Posts[postListToDelete.Order[numPost-1]]
+	lastPost := postListToDelete.Posts[postListToDelete.Order[0]]
+
+	dialog := &model.OpenDialogRequest{
+		TriggerId: options.triggerID,
+		URL:       fmt.Sprintf("%s/plugins/%s%s", *siteURL, manifest.Id, routeDialogDeleteFrom),
+		Dialog: model.Dialog{
+			CallbackId: "confirmPostDeletion",
+			Title: fmt.Sprintf(
+				"Do you want to delete %d post%s in this channel?",
+				numPost, getPluralChar(numPost),
+			),
+			IntroductionText: fmt.Sprintf(
+				"**From:** %s\n\n**To:** %s",
+				p.getPostPreview(firstPost), p.getPostPreview(lastPost),
+			),
+			SubmitLabel:    "Confirm",
+			NotifyOnCancel: false,
+			State:          options.getDialogState(options.fromPostID),
+			Elements: []model.DialogElement{
+				{
+					Type:        "bool",
+					Name:        "deletePinnedPosts",
+					DisplayName: "Delete pinned posts?",
+					HelpText:    "",
+					Default:     strconv.FormatBool(options.optDeletePinnedPosts),
+					Optional:    true,
+				},
+			},
+		},
+	}
+
+	if err := p.API.OpenInteractiveDialog(*dialog); err != nil {
+		p.API.LogError("Failed to open Interactive Dialog", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Failed to open Interactive Dialog")
+	}
+}
+
+func (p *Plugin) deleteFromPostsInChannel(options *deletionOptions) {
+	hasPermissionToDeletePost := canDeletePost(p, options.userID, options.channelID)
+	if !hasPermissionToDeletePost {
+		p.sendEphemeralPost(options.userID, options.channelID, "Sorry, you are not permitted to delete posts")
+		return
+	}
+
+	postList, err := p.getPostsFrom(options.channelID, options.fromPostID)
+	if err != nil {
+		p.API.LogError("Unable to retrieve posts", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when deleting posts")
+		return
+	}
+
+	p.deletePostsAndReport(postList, options)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+)
+
+func TestParsePostIDArg(t *testing.T) {
+	postID := model.NewId()
+
+	for name, tc := range map[string]struct {
+		value         string
+		expected      string
+		expectedError bool
+	}{
+		"post ID":                {value: postID, expected: postID},
+		"permalink":              {value: "https://chat.example.com/team/pl/" + postID, expected: postID},
+		"permalink with a slash": {value: "https://chat.example.com/team/pl/" + postID + "/", expected: postID},
+		"relative permalink":     {value: "/team/pl/" + postID, expected: postID},
+		"channel link":           {value: "https://chat.example.com/team/channels/town-square", expectedError: true},
+		"short ID":               {value: postID[:20], expectedError: true},
+		"uppercase ID":           {value: "https://chat.example.com/team/pl/ABCDEFGHIJKLMNOPQRSTUVWXYZ", expectedError: true},
+		"empty":                  {value: "", expectedError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := parsePostIDArg(tc.value)
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", parsed)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestGetFromPost(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("GetPost", "post").Return(&model.Post{Id: "post", ChannelId: "channel"}, nil)
+	api.On("GetPost", "missing").Return(nil, model.NewAppError("GetPost", "not found", nil, "", http.StatusNotFound))
+
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	for name, tc := range map[string]struct {
+		postID        string
+		channelID     string
+		expectedError bool
+	}{
+		"post of the channel":     {postID: "post", channelID: "channel"},
+		"post of another channel": {postID: "post", channelID: "other", expectedError: true},
+		"post not found":          {postID: "missing", channelID: "channel", expectedError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			post, userErr := p.getFromPost(tc.postID, tc.channelID)
+			if tc.expectedError {
+				if userErr == nil {
+					t.Fatalf("expected an error, got post %v", post)
+				}
+				return
+			}
+
+			if userErr != nil {
+				t.Fatalf("unexpected error: %v", userErr)
+			}
+			if post.Id != tc.postID {
+				t.Errorf("expected post %q, got %q", tc.postID, post.Id)
+			}
+		})
+	}
+}
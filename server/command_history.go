@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	historyTrigger  = "history"
+	historyHint     = ""
+	historyHelpText = "List the recent housecleanings of the channel (admins only)"
+
+	argChannel = "channel"
+	argLimit   = "limit"
+
+	// historyAllChannels is the value of --channel to list the housecleanings of every channel
+	historyAllChannels = "all"
+
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// historyOptions contains the arguments of the history subcommand
+type historyOptions struct {
+	channelName string
+	channelID   string
+	limit       int
+}
+
+func getHistoryAutocompleteData() *model.AutocompleteData {
+	history := model.NewAutocompleteData(historyTrigger, historyHint, historyHelpText)
+	history.AddNamedTextArgument(argChannel, "Only list the housecleanings of this channel, or of all the channels (system admins only)", "~channel|all", "", false)
+	history.AddNamedDynamicListArgument(argUser, "Only list the housecleanings of this user (can be repeated)", routeAutocompleteUsers, false)
+	history.AddNamedTextArgument(argLimit, fmt.Sprintf("The number of housecleanings to list (%d by default)", defaultHistoryLimit), "[number]", "[0-9]+", false)
+
+	return history
+}
+
+// parseHistoryArgs resolves the channel given to --channel, the current one by default
+func (p *Plugin) parseHistoryArgs(args *model.CommandArgs, positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) > 0 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[0])
+	}
+
+	switch options.history.channelName {
+	case "":
+		options.history.channelID = args.ChannelId
+	case historyAllChannels:
+		options.history.channelID = ""
+	default:
+		channelName := strings.TrimPrefix(options.history.channelName, "~")
+		channel, appErr := p.API.GetChannelByName(args.TeamId, channelName, false)
+		if appErr != nil {
+			return errors.Errorf("Unable to find the channel `~%s`", channelName)
+		}
+		options.history.channelID = channel.Id
+	}
+
+	return nil
+}
+
+func (p *Plugin) executeHistory(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if options.history.channelID == "" {
+		if !isSysadmin(p, options.userID) {
+			return p.respondEphemeralPost(options, "Sorry, only system admins can list the housecleanings of all the channels"), nil
+		}
+	} else if !p.API.HasPermissionToChannel(options.userID, options.history.channelID, model.PermissionManageChannelRoles) {
+		return p.respondEphemeralPost(options, "Sorry, only the admins of the channel can list its housecleanings"), nil
+	}
+
+	records, err := p.getHistory(options.history.channelID, options.filters.UserIDs, options.history.limit)
+	if err != nil {
+		p.API.LogError("Unable to get the history", "err", err)
+		return p.respondEphemeralPost(options, "Error when retrieving the history"), nil
+	}
+
+	if len(records) == 0 {
+		return p.respondEphemeralPost(options, "No housecleaning found."), nil
+	}
+
+	location := p.getUserLocation(options.userID)
+	message := "#### Recent housecleanings\n" +
+		"| Date | User | Channel | Command | Selectors | Deleted | Skipped | Errors | Archive |\n" +
+		"|:--|:--|:--|:--|:--|--:|--:|--:|:--|\n"
+
+	for _, record := range records {
+		archive := ""
+		if record.ArchiveLink != "" {
+			archive = fmt.Sprintf("[Archive](%s)", record.ArchiveLink)
+		}
+
+		message += fmt.Sprintf(
+			"| %s | %s | ~%s | `%s` | %s | %d | %d | %d | %s |\n",
+			formatTime(record.CreateAt, location),
+			p.getUserMention(record.UserID),
+			p.getChannelName(record.ChannelID),
+			escapeTableCell(record.Command),
+			escapeTableCell(strings.Join(record.Selectors, ", ")),
+			record.Deleted,
+			record.SkippedPinned+record.SkippedNotPermitted+record.SkippedProtected+record.SkippedThreads,
+			record.Errors,
+			archive,
+		)
+	}
+
+	return p.respondEphemeralPost(options, message), nil
+}
+
+// escapeTableCell escapes the characters breaking a Markdown table
+func escapeTableCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -21,8 +22,31 @@ func getLastAutocompleteData(conf *configuration) *model.AutocompleteData {
 	return last
 }
 
+// parseLastArgs checks the [number-of-posts] argument and stores it in options
+func (p *Plugin) parseLastArgs(args *model.CommandArgs, positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) == 0 {
+		return errors.Errorf("Please specify the number of posts to delete: `/broom %s %s`", lastTrigger, lastHint)
+	}
+
+	if len(positionalArgs) > 1 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[1])
+	}
+
+	numPostToDelete64, err := strconv.ParseInt(positionalArgs[0], 10, 0)
+	if err != nil {
+		return errors.Errorf("Incorrect argument. [number-of-post] must be an integer")
+	}
+
+	if numPostToDelete64 < 1 {
+		return errors.Errorf("You may want to delete at least one post :wink: ")
+	}
+
+	options.numPost = int(numPostToDelete64)
+	return nil
+}
+
 func (p *Plugin) executeLast(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
-	if p.shouldConfirmDeletion(options.optNoConfirmDialog) {
+	if p.shouldConfirmDeletion(options) {
 		p.sendDialogDeleteLast(options)
 	} else {
 		p.deleteLastPostsInChannel(options)
@@ -34,6 +58,17 @@ func (p *Plugin) executeLast(options *deletionOptions) (*model.CommandResponse,
 func (p *Plugin) sendDialogDeleteLast(options *deletionOptions) {
 	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
 
+	introduction := ""
+	if options.filters.hasTextFilter() {
+		postList, appErr := p.getLastPosts(options.channelID, options.numPost)
+		if appErr != nil {
+			p.API.LogError("Unable to retrieve posts", "appErr", appErr)
+			p.sendEphemeralPost(options.userID, options.channelID, "Error when retrieving the posts to delete")
+			return
+		}
+		introduction = options.filters.getMatchedCountText(getRelevantPostList(postList))
+	}
+
 	dialog := &model.OpenDialogRequest{
 		TriggerId: options.triggerID,
 		URL:       fmt.Sprintf("%s/plugins/%s%s", *siteURL, manifest.Id, routeDialogDeleteLast),
@@ -43,9 +78,10 @@ func (p *Plugin) sendDialogDeleteLast(options *deletionOptions) {
 				"Do you want to delete the last %d post%s in this channel?",
 				options.numPost, getPluralChar(options.numPost),
 			),
-			SubmitLabel:    "Confirm",
-			NotifyOnCancel: false,
-			State:          strconv.Itoa(options.numPost),
+			IntroductionText: introduction,
+			SubmitLabel:      "Confirm",
+			NotifyOnCancel:   false,
+			State:            options.getDialogState(strconv.Itoa(options.numPost)),
 			Elements: []model.DialogElement{
 				{
 					Type:        "bool",
@@ -68,22 +104,16 @@ func (p *Plugin) sendDialogDeleteLast(options *deletionOptions) {
 func (p *Plugin) deleteLastPostsInChannel(options *deletionOptions) {
 	hasPermissionToDeletePost := canDeletePost(p, options.userID, options.channelID)
 	if !hasPermissionToDeletePost {
-		p.sendEphemeralPost(options.channelID, options.userID, "Sorry, you are not permitted to delete posts")
+		p.sendEphemeralPost(options.userID, options.channelID, "Sorry, you are not permitted to delete posts")
 		return
 	}
 
-	postList, appErr := p.API.GetPostsForChannel(options.channelID, 0, options.numPost)
+	postList, appErr := p.getLastPosts(options.channelID, options.numPost)
 	if appErr != nil {
 		p.API.LogError("Unable to retrieve posts", "appErr", appErr)
-		p.sendEphemeralPost(options.channelID, options.userID, "Error when deleting posts")
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when deleting posts")
 		return
 	}
 
-	beginningPost := p.sendEphemeralPost(options.userID, options.channelID, messageBeginning)
-
-	postListToDelete := getRelevantPostList(postList)
-	result := p.deletePosts(postListToDelete, options)
-
-	beginningPost.Message = result.String()
-	p.API.UpdateEphemeralPost(options.userID, beginningPost)
+	p.deletePostsAndReport(postList, options)
 }
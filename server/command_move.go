@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	moveTrigger  = "move"
+	moveHint     = "[number-of-posts] [~channel]"
+	moveHelpText = "Move the last [number-of-posts] posts of the channel to [~channel]"
+
+	// Props added to the moved posts to keep track of the original ones
+	propMovedFromChannelID = "broomer_moved_from_channel_id"
+	propMovedFromPostID    = "broomer_moved_from_post_id"
+	propOriginalUserID     = "broomer_original_user_id"
+	propOriginalCreateAt   = "broomer_original_create_at"
+)
+
+func getMoveAutocompleteData(conf *configuration) *model.AutocompleteData {
+	move := model.NewAutocompleteData(moveTrigger, moveHint, moveHelpText)
+	move.AddTextArgument("The number of posts to move", "[number-of-posts]", "[0-9]+")
+	move.AddTextArgument("The channel to move the posts to", "[~channel]", "")
+	addAllNamedTextArgumentsToCmd(move, conf.AskConfirm == askConfirmOptional)
+
+	return move
+}
+
+// parseMoveArgs checks the [number-of-posts] and [~channel] arguments and stores them in options
+func (p *Plugin) parseMoveArgs(args *model.CommandArgs, positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) != 2 {
+		return errors.Errorf("Please specify the number of posts to move and the channel: `/broom %s %s`", moveTrigger, moveHint)
+	}
+
+	if userErr := p.parseLastArgs(args, positionalArgs[:1], options); userErr != nil {
+		return userErr
+	}
+
+	channelName := strings.TrimPrefix(positionalArgs[1], "~")
+	targetChannel, appErr := p.API.GetChannelByName(args.TeamId, channelName, false)
+	if appErr != nil {
+		return errors.Errorf("Unable to find the channel `~%s`", channelName)
+	}
+
+	if targetChannel.Id == args.ChannelId {
+		return errors.Errorf("The posts are already in this channel :wink:")
+	}
+
+	if !p.API.HasPermissionToChannel(args.UserId, targetChannel.Id, model.PermissionCreatePost) {
+		return errors.Errorf("You are not permitted to post in `~%s`", channelName)
+	}
+
+	options.moveChannelID = targetChannel.Id
+	return nil
+}
+
+func (p *Plugin) executeMove(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if p.shouldConfirmDeletion(options) {
+		p.sendDialogMove(options)
+	} else {
+		p.moveLastPostsInChannel(options)
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+func (p *Plugin) sendDialogMove(options *deletionOptions) {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+
+	targetChannelName := options.moveChannelID
+	if targetChannel, appErr := p.API.GetChannel(options.moveChannelID); appErr == nil {
+		targetChannelName = targetChannel.DisplayName
+	}
+
+	dialog := &model.OpenDialogRequest{
+		TriggerId: options.triggerID,
+		URL:       fmt.Sprintf("%s/plugins/%s%s", *siteURL, manifest.Id, routeDialogMove),
+		Dialog: model.Dialog{
+			CallbackId: "confirmPostMove",
+			Title: fmt.Sprintf(
+				"Do you want to move the last %d post%s of this channel to %s?",
+				options.numPost, getPluralChar(options.numPost), targetChannelName,
+			),
+			SubmitLabel:    "Confirm",
+			NotifyOnCancel: false,
+			State:          options.getDialogState(fmt.Sprintf("%d %s", options.numPost, options.moveChannelID)),
+			Elements: []model.DialogElement{
+				{
+					Type:        "bool",
+					Name:        "deletePinnedPosts",
+					DisplayName: "Move pinned posts?",
+					HelpText:    "",
+					Default:     strconv.FormatBool(options.optDeletePinnedPosts),
+					Optional:    true,
+				},
+			},
+		},
+	}
+
+	if err := p.API.OpenInteractiveDialog(*dialog); err != nil {
+		p.API.LogError("Failed to open Interactive Dialog", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Failed to open Interactive Dialog")
+	}
+}
+
+func (p *Plugin) moveLastPostsInChannel(options *deletionOptions) {
+	hasPermissionToDeletePost := canDeletePost(p, options.userID, options.channelID)
+	if !hasPermissionToDeletePost {
+		p.sendEphemeralPost(options.userID, options.channelID, "Sorry, you are not permitted to delete posts")
+		return
+	}
+
+	if p.isChannelProtected(options.channelID) {
+		p.sendEphemeralPost(options.userID, options.channelID, messageChannelProtected)
+		return
+	}
+
+	postList, appErr := p.getLastPosts(options.channelID, options.numPost)
+	if appErr != nil {
+		p.API.LogError("Unable to retrieve posts", "appErr", appErr)
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when moving posts")
+		return
+	}
+
+	p.deletePostsAndReport(postList, options)
+}
+
+// copyMovedPosts copies the posts of postList matching the criteria of options, along with their threads,
+// to the target channel. Like in deletePosts, a root post whose thread has replies that are not selected,
+// like protected replies, is not copied, as it won't be deleted: only its selected replies are.
+// If some posts can't be copied, the copies are removed, along with their files, so that moving the posts again
+// doesn't duplicate them. Returns the number of copied posts
+func (p *Plugin) copyMovedPosts(postList *model.PostList, options *deletionOptions) (int, error) {
+	selected := p.selectPostsToDelete(postList, options, new(deletePostResult))
+	p.fillMissingReplyCounts(selected)
+	keptRoots := getKeptRoots(selected)
+
+	// The posts are ordered from the most recent one, so the replies are met before their root
+	selectedReplies := map[string][]*model.Post{}
+	postsToCopy := []*model.Post{}
+	for _, postID := range selected.Order {
+		post := selected.Posts[postID]
+
+		if _, ok := selected.Posts[post.RootId]; post.RootId != "" && ok && !keptRoots[post.RootId] {
+			// copied with the thread of its root post
+			selectedReplies[post.RootId] = append(selectedReplies[post.RootId], post)
+			continue
+		}
+
+		if keptRoots[post.Id] {
+			continue // process next post
+		}
+
+		posts, err := p.getPostWithThread(post, selectedReplies[post.Id])
+		if err != nil {
+			return 0, err
+		}
+		postsToCopy = append(postsToCopy, posts...)
+	}
+
+	// The files are copied right before creating each post, so the copies belong to a copied post,
+	// and are deleted with it if the move fails
+	copiedPostIDs, numFailed := p.recreatePosts(postsToCopy, options.moveChannelID, func(original *model.Post, post *model.Post) error {
+		post.CreateAt = 0
+		post.IsPinned = false
+		post.AddProp(propMovedFromChannelID, original.ChannelId)
+		post.AddProp(propMovedFromPostID, original.Id)
+		post.AddProp(propOriginalUserID, original.UserId)
+		post.AddProp(propOriginalCreateAt, original.CreateAt)
+
+		if len(original.FileIds) > 0 {
+			fileIDs, appErr := p.API.CopyFileInfos(original.UserId, original.FileIds)
+			if appErr != nil {
+				return errors.Wrap(appErr, "failed to copy files")
+			}
+			post.FileIds = fileIDs
+		}
+
+		return nil
+	})
+
+	if numFailed > 0 {
+		p.deleteCopiedPosts(copiedPostIDs)
+		return 0, errors.Errorf("failed to copy %d posts", numFailed)
+	}
+
+	return len(copiedPostIDs), nil
+}
+
+// postMoveBreadcrumb tells the members of the channel where the posts have been moved
+func (p *Plugin) postMoveBreadcrumb(options *deletionOptions, numMoved int) {
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: options.channelID,
+		Message: fmt.Sprintf(
+			"%s moved %d post%s to ~%s.",
+			p.getUserMention(options.userID), numMoved, getPluralChar(numMoved), p.getChannelName(options.moveChannelID),
+		),
+	}); appErr != nil {
+		p.API.LogError("Unable to post the move breadcrumb", "appErr", appErr)
+	}
+}
+
+// deleteCopiedPosts deletes the posts copied to the target channel, from the most recent one
+func (p *Plugin) deleteCopiedPosts(postIDs []string) {
+	for i := len(postIDs) - 1; i >= 0; i-- {
+		// The reply may have been deleted along with its root already
+		if appErr := p.API.DeletePost(postIDs[i]); appErr != nil && appErr.StatusCode != http.StatusNotFound {
+			p.API.LogError("Unable to delete copied post", "PostID", postIDs[i], "appErr", appErr)
+		}
+	}
+}
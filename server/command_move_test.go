@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyMovedPosts(t *testing.T) {
+	// From the most recent post, like the channel history
+	getPostList := func() *model.PostList {
+		postList := model.NewPostList()
+		for _, post := range []*model.Post{
+			{Id: "protectedReply", UserId: "releasebot", ChannelId: "channel", RootId: "keptRoot", CreateAt: 6},
+			{Id: "otherReply", UserId: "other", ChannelId: "channel", RootId: "keptRoot", CreateAt: 5},
+			{Id: "keptRoot", UserId: "user", ChannelId: "channel", ReplyCount: 2, CreateAt: 4},
+			{Id: "reply", UserId: "other", ChannelId: "channel", RootId: "root", CreateAt: 3},
+			{Id: "root", UserId: "user", ChannelId: "channel", ReplyCount: 1, FileIds: model.StringArray{"file"}, CreateAt: 2},
+		} {
+			postList.AddPost(post)
+			postList.AddOrder(post.Id)
+		}
+		return postList
+	}
+
+	overrides, err := json.Marshal(&channelAuthorOverrides{ChannelID: "channel", ProtectedUserIDs: []string{"releasebot"}})
+	require.NoError(t, err)
+
+	isCopyOf := func(postID string) any {
+		return mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "target" && post.GetProp(propMovedFromPostID) == postID
+		})
+	}
+
+	options := &deletionOptions{channelID: "channel", userID: "user", moveChannelID: "target", permDeleteOthersPosts: true}
+
+	t.Run("protected reply kept with its root", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", getProtectedAuthorsKey("channel")).Return(overrides, nil)
+		api.On("CopyFileInfos", "user", []string{"file"}).Return([]string{"copy"}, nil).Once()
+		api.On("GetPost", "keptRoot").Return(&model.Post{Id: "keptRoot", ChannelId: "channel"}, nil).Once()
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.GetProp(propMovedFromPostID) == "root" && len(post.FileIds) == 1 && post.FileIds[0] == "copy"
+		})).Return(&model.Post{Id: "newRoot"}, nil).Once()
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.GetProp(propMovedFromPostID) == "reply" && post.RootId == "newRoot"
+		})).Return(&model.Post{Id: "newReply"}, nil).Once()
+		api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.GetProp(propMovedFromPostID) == "otherReply" && post.RootId == ""
+		})).Return(&model.Post{Id: "newOtherReply"}, nil).Once()
+		defer api.AssertExpectations(t)
+
+		p := &Plugin{}
+		p.SetAPI(api)
+		p.client = pluginapi.NewClient(api, nil)
+
+		numCopied, err := p.copyMovedPosts(getPostList(), options)
+		require.NoError(t, err)
+		assert.Equal(t, 3, numCopied)
+		api.AssertNotCalled(t, "CreatePost", isCopyOf("keptRoot"))
+		api.AssertNotCalled(t, "CreatePost", isCopyOf("protectedReply"))
+		api.AssertNotCalled(t, "GetPostThread", mock.Anything)
+	})
+
+	t.Run("copies removed on failure", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("KVGet", getProtectedAuthorsKey("channel")).Return(overrides, nil)
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		api.On("CopyFileInfos", "user", []string{"file"}).Return([]string{"copy"}, nil).Once()
+		api.On("GetPost", "keptRoot").Return(&model.Post{Id: "keptRoot", ChannelId: "channel"}, nil).Once()
+		api.On("CreatePost", isCopyOf("root")).Return(&model.Post{Id: "newRoot"}, nil).Once()
+		api.On("CreatePost", isCopyOf("reply")).Return(&model.Post{Id: "newReply"}, nil).Once()
+		api.On("CreatePost", isCopyOf("otherReply")).Return(nil, model.NewAppError("CreatePost", "error", nil, "", http.StatusInternalServerError)).Once()
+		api.On("DeletePost", "newReply").Return(nil).Once()
+		api.On("DeletePost", "newRoot").Return(nil).Once()
+		defer api.AssertExpectations(t)
+
+		p := &Plugin{}
+		p.SetAPI(api)
+		p.client = pluginapi.NewClient(api, nil)
+
+		_, err := p.copyMovedPosts(getPostList(), options)
+		require.Error(t, err)
+	})
+}
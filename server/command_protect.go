@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	protectTrigger  = "protect"
+	protectHelpText = "Forbid to delete posts in the channel, or only the posts of the users given with `--user` (system admins only)"
+
+	unprotectTrigger  = "unprotect"
+	unprotectHelpText = "Allow again to delete posts in the channel, or the posts of the users given with `--user` (system admins only)"
+)
+
+func getProtectAutocompleteData() *model.AutocompleteData {
+	protect := model.NewAutocompleteData(protectTrigger, "", protectHelpText)
+	protect.RoleID = model.SystemAdminRoleId
+	protect.AddNamedDynamicListArgument(argUser, "Only protect the posts of this user in the channel (can be repeated)", routeAutocompleteUsers, false)
+
+	return protect
+}
+
+func getUnprotectAutocompleteData() *model.AutocompleteData {
+	unprotect := model.NewAutocompleteData(unprotectTrigger, "", unprotectHelpText)
+	unprotect.RoleID = model.SystemAdminRoleId
+	unprotect.AddNamedDynamicListArgument(argUser, "Only unprotect the posts of this user in the channel, even if protected by the plugin settings (can be repeated)", routeAutocompleteUsers, false)
+
+	return unprotect
+}
+
+// parseProtectArgs checks that the protect and unprotect subcommands have no arguments
+func (p *Plugin) parseProtectArgs(positionalArgs []string) userError {
+	if len(positionalArgs) > 0 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[0])
+	}
+
+	return nil
+}
+
+func (p *Plugin) executeProtect(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if !isSysadmin(p, options.userID) {
+		return p.respondEphemeralPost(options, "Sorry, only system admins can protect channels"), nil
+	}
+
+	if len(options.filters.UserIDs) > 0 {
+		if err := p.protectAuthors(options.channelID, options.filters.UserIDs); err != nil {
+			p.API.LogError("Unable to protect authors", "err", err)
+			return p.respondEphemeralPost(options, "Error when protecting the posts"), nil
+		}
+
+		return p.respondEphemeralPost(options, fmt.Sprintf(
+			"The posts of %s in this channel are now protected: they can't be broomed anymore.",
+			p.getUserMentions(options.filters.UserIDs),
+		)), nil
+	}
+
+	if err := p.protectChannel(options.channelID, options.userID); err != nil {
+		p.API.LogError("Unable to protect channel", "err", err)
+		return p.respondEphemeralPost(options, "Error when protecting the channel"), nil
+	}
+
+	return p.respondEphemeralPost(options, "This channel is now protected: its posts can't be broomed anymore, even by the scheduled housecleanings and the retention policies."), nil
+}
+
+func (p *Plugin) executeUnprotect(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if !isSysadmin(p, options.userID) {
+		return p.respondEphemeralPost(options, "Sorry, only system admins can unprotect channels"), nil
+	}
+
+	if len(options.filters.UserIDs) > 0 {
+		if err := p.unprotectAuthors(options.channelID, options.filters.UserIDs); err != nil {
+			p.API.LogError("Unable to unprotect authors", "err", err)
+			return p.respondEphemeralPost(options, "Error when unprotecting the posts"), nil
+		}
+
+		return p.respondEphemeralPost(options, fmt.Sprintf(
+			"The posts of %s in this channel are not protected anymore.",
+			p.getUserMentions(options.filters.UserIDs),
+		)), nil
+	}
+
+	if err := p.unprotectChannel(options.channelID); err != nil {
+		p.API.LogError("Unable to unprotect channel", "err", err)
+		return p.respondEphemeralPost(options, "Error when unprotecting the channel"), nil
+	}
+
+	if p.isChannelProtectedBySettings(options.channelID) {
+		return p.respondEphemeralPost(options, "This channel is still protected by the plugin settings."), nil
+	}
+
+	return p.respondEphemeralPost(options, "This channel is not protected anymore."), nil
+}
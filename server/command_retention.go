@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	retentionTrigger  = "retention"
+	retentionHint     = "[set|show|remove]"
+	retentionHelpText = "Manage the retention policy of the channel"
+
+	retentionSetTrigger  = "set"
+	retentionSetHint     = "[days]"
+	retentionSetHelpText = "Automatically delete the posts of the channel older than [days] days"
+
+	retentionShowTrigger  = "show"
+	retentionShowHelpText = "Show the retention policy of the channel"
+
+	retentionRemoveTrigger  = "remove"
+	retentionRemoveHelpText = "Stop deleting the old posts of the channel"
+)
+
+// retentionOptions contains the arguments of the retention subcommand
+type retentionOptions struct {
+	action string
+	days   int
+}
+
+func getRetentionAutocompleteData() *model.AutocompleteData {
+	retention := model.NewAutocompleteData(retentionTrigger, retentionHint, retentionHelpText)
+
+	set := model.NewAutocompleteData(retentionSetTrigger, retentionSetHint, retentionSetHelpText)
+	set.AddTextArgument("The number of days the posts are kept", retentionSetHint, "[0-9]+")
+	retention.AddCommand(set)
+
+	retention.AddCommand(model.NewAutocompleteData(retentionShowTrigger, "", retentionShowHelpText))
+	retention.AddCommand(model.NewAutocompleteData(retentionRemoveTrigger, "", retentionRemoveHelpText))
+
+	return retention
+}
+
+// parseRetentionArgs checks the arguments of the retention subcommand and stores them in options
+func (p *Plugin) parseRetentionArgs(positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) == 0 {
+		return errors.Errorf("Please specify what to do: `/broom %s %s`", retentionTrigger, retentionHint)
+	}
+
+	options.retention = &retentionOptions{action: positionalArgs[0]}
+
+	switch positionalArgs[0] {
+	case retentionSetTrigger:
+		if len(positionalArgs) != 2 {
+			return errors.Errorf("Please specify the number of days: `/broom %s %s %s`", retentionTrigger, retentionSetTrigger, retentionSetHint)
+		}
+
+		days, err := strconv.Atoi(positionalArgs[1])
+		if err != nil || days < 1 {
+			return errors.Errorf("The number of days `%s` should be a positive number", positionalArgs[1])
+		}
+		options.retention.days = days
+
+	case retentionShowTrigger, retentionRemoveTrigger:
+		if len(positionalArgs) > 1 {
+			return errors.Errorf("Invalid argument `%s`", positionalArgs[1])
+		}
+
+	default:
+		return errors.Errorf("Unknown action `%s`. Type `/broom %s` to learn how to broom", positionalArgs[0], helpTrigger)
+	}
+
+	return nil
+}
+
+func (p *Plugin) executeRetention(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if options.retention.action == retentionShowTrigger {
+		return p.executeRetentionShow(options)
+	}
+
+	// The retention policy deletes the posts of everyone
+	if !canDeleteOthersPosts(p, options.userID, options.channelID) {
+		return p.respondEphemeralPost(options, "Sorry, only the users permitted to delete the posts of others can manage the retention policy"), nil
+	}
+
+	if options.retention.action == retentionRemoveTrigger {
+		if err := p.deleteRetentionPolicy(options.channelID); err != nil {
+			p.API.LogError("Unable to delete retention policy", "err", err)
+			return p.respondEphemeralPost(options, "Error when removing the retention policy"), nil
+		}
+
+		return p.respondEphemeralPost(options, "The posts of this channel will not be deleted automatically anymore."), nil
+	}
+
+	policy := &retentionPolicy{
+		ChannelID: options.channelID,
+		CreatorID: options.userID,
+		Days:      options.retention.days,
+		CreateAt:  model.GetMillis(),
+	}
+
+	if err := p.saveRetentionPolicy(policy); err != nil {
+		p.API.LogError("Unable to save retention policy", "err", err)
+		return p.respondEphemeralPost(options, "Error when saving the retention policy"), nil
+	}
+
+	return p.respondEphemeralPost(options, fmt.Sprintf(
+		"The posts of this channel older than %d day%s will be deleted every hour, except the pinned ones.",
+		policy.Days, getPluralChar(policy.Days),
+	)), nil
+}
+
+func (p *Plugin) executeRetentionShow(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	policy, err := p.getRetentionPolicy(options.channelID)
+	if err != nil {
+		p.API.LogError("Unable to get retention policy", "err", err)
+		return p.respondEphemeralPost(options, "Error when retrieving the retention policy"), nil
+	}
+
+	if policy == nil {
+		return p.respondEphemeralPost(options, "This channel has no retention policy."), nil
+	}
+
+	lastSweep := "never"
+	if policy.LastSweepAt > 0 {
+		lastSweep = formatTime(policy.LastSweepAt, p.getUserLocation(options.userID))
+	}
+
+	return p.respondEphemeralPost(options, fmt.Sprintf(
+		"The posts of this channel older than %d day%s are deleted, except the pinned ones. Set up by %s, last applied: %s.",
+		policy.Days, getPluralChar(policy.Days), p.getUserMention(policy.CreatorID), lastSweep,
+	)), nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	scheduleTrigger  = "schedule"
+	scheduleHint     = "[add|list|remove]"
+	scheduleHelpText = "Manage the housecleanings run periodically in the channel"
+
+	scheduleAddTrigger  = "add"
+	scheduleAddHint     = "\"[cron-expression]\" [command]"
+	scheduleAddHelpText = "Run [command] periodically, for example `/broom schedule add \"0 3 * * *\" since 1d`"
+
+	scheduleListTrigger  = "list"
+	scheduleListHelpText = "List the scheduled housecleanings of the channel"
+
+	scheduleRemoveTrigger  = "remove"
+	scheduleRemoveHint     = "[schedule-id]"
+	scheduleRemoveHelpText = "Remove a scheduled housecleaning"
+)
+
+// Subcommands that can be scheduled
+var schedulableTriggers = []string{lastTrigger, sinceTrigger}
+
+// scheduleOptions contains the arguments of the schedule subcommand
+type scheduleOptions struct {
+	action     string
+	cronExpr   string
+	selector   string
+	scheduleID string
+}
+
+func getScheduleAutocompleteData() *model.AutocompleteData {
+	schedule := model.NewAutocompleteData(scheduleTrigger, scheduleHint, scheduleHelpText)
+
+	add := model.NewAutocompleteData(scheduleAddTrigger, scheduleAddHint, scheduleAddHelpText)
+	add.AddTextArgument("A cron expression between double quotes, like \"0 3 * * *\", or @hourly, @daily, @weekly, @monthly", "\"[cron-expression]\"", "")
+	add.AddTextArgument("The command to run, like `last 100` or `since 1d`", "[command]", "")
+	schedule.AddCommand(add)
+
+	schedule.AddCommand(model.NewAutocompleteData(scheduleListTrigger, "", scheduleListHelpText))
+
+	remove := model.NewAutocompleteData(scheduleRemoveTrigger, scheduleRemoveHint, scheduleRemoveHelpText)
+	remove.AddTextArgument("The ID of the schedule, as shown by /broom schedule list", scheduleRemoveHint, "")
+	schedule.AddCommand(remove)
+
+	return schedule
+}
+
+// parseScheduleArgs checks the arguments of the schedule subcommand and stores them in options
+func (p *Plugin) parseScheduleArgs(args *model.CommandArgs, rawArgs []string, options *deletionOptions) userError {
+	if len(rawArgs) == 0 {
+		return errors.Errorf("Please specify what to do: `/broom %s %s`", scheduleTrigger, scheduleHint)
+	}
+
+	options.schedule = &scheduleOptions{action: rawArgs[0]}
+
+	switch rawArgs[0] {
+	case scheduleAddTrigger:
+		if len(rawArgs) < 3 {
+			return errors.Errorf("Please specify when and what to run: `/broom %s %s %s`", scheduleTrigger, scheduleAddTrigger, scheduleAddHint)
+		}
+
+		if _, err := parseCron(rawArgs[1]); err != nil {
+			return err
+		}
+
+		selector := joinCommandArgs(rawArgs[2:])
+		if !contains(schedulableTriggers, rawArgs[2]) {
+			return errors.Errorf("Only the `%s` and `%s` commands can be scheduled", lastTrigger, sinceTrigger)
+		}
+
+		// Check the scheduled command is valid. The quota is checked each time it runs
+		if _, _, userErr := p.parseCommandArgs(&model.CommandArgs{
+			Command:   "/broom " + selector,
+			UserId:    args.UserId,
+			ChannelId: args.ChannelId,
+			TeamId:    args.TeamId,
+		}); userErr != nil {
+			return userErr
+		}
+
+		options.schedule.cronExpr = rawArgs[1]
+		options.schedule.selector = selector
+
+	case scheduleListTrigger:
+		if len(rawArgs) > 1 {
+			return errors.Errorf("Invalid argument `%s`", rawArgs[1])
+		}
+
+	case scheduleRemoveTrigger:
+		if len(rawArgs) != 2 {
+			return errors.Errorf("Please specify the schedule to remove: `/broom %s %s %s`", scheduleTrigger, scheduleRemoveTrigger, scheduleRemoveHint)
+		}
+		options.schedule.scheduleID = rawArgs[1]
+
+	default:
+		return errors.Errorf("Unknown action `%s`. Type `/broom %s` to learn how to broom", rawArgs[0], helpTrigger)
+	}
+
+	return nil
+}
+
+func (p *Plugin) executeSchedule(args *model.CommandArgs, options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	switch options.schedule.action {
+	case scheduleAddTrigger:
+		return p.executeScheduleAdd(args, options)
+	case scheduleRemoveTrigger:
+		return p.executeScheduleRemove(options)
+	default:
+		return p.executeScheduleList(options)
+	}
+}
+
+func (p *Plugin) executeScheduleAdd(args *model.CommandArgs, options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if !canDeletePost(p, options.userID, options.channelID) {
+		return p.respondEphemeralPost(options, "Sorry, you are not permitted to delete posts"), nil
+	}
+
+	schedule := &channelSchedule{
+		ID:        model.NewId(),
+		ChannelID: options.channelID,
+		TeamID:    args.TeamId,
+		CreatorID: options.userID,
+		CronExpr:  options.schedule.cronExpr,
+		Timezone:  p.getUserLocation(options.userID).String(),
+		Selector:  options.schedule.selector,
+		CreateAt:  model.GetMillis(),
+	}
+
+	if err := p.updateSchedules(func(schedules []*channelSchedule) ([]*channelSchedule, error) {
+		return append(schedules, schedule), nil
+	}); err != nil {
+		p.API.LogError("Unable to save schedule", "err", err)
+		return p.respondEphemeralPost(options, "Error when saving the schedule"), nil
+	}
+
+	return p.respondEphemeralPost(options, fmt.Sprintf(
+		"`/broom %s` will run in this channel on schedule `%s` (%s). Its ID is `%s`.",
+		schedule.Selector, schedule.CronExpr, schedule.Timezone, schedule.ID,
+	)), nil
+}
+
+func (p *Plugin) executeScheduleList(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	schedules, err := p.getSchedules()
+	if err != nil {
+		p.API.LogError("Unable to get schedules", "err", err)
+		return p.respondEphemeralPost(options, "Error when retrieving the schedules"), nil
+	}
+
+	location := p.getUserLocation(options.userID)
+	message := ""
+	for _, schedule := range schedules {
+		if schedule.ChannelID != options.channelID {
+			continue
+		}
+
+		lastRun := "never"
+		if schedule.LastRunAt > 0 {
+			lastRun = formatTime(schedule.LastRunAt, location)
+		}
+
+		message += fmt.Sprintf(
+			"| `%s` | `%s` (%s) | `/broom %s` | %s | %s |\n",
+			schedule.ID, schedule.CronExpr, schedule.Timezone, schedule.Selector,
+			p.getUserMention(schedule.CreatorID), lastRun,
+		)
+	}
+
+	if message == "" {
+		return p.respondEphemeralPost(options, "There are no scheduled housecleanings in this channel."), nil
+	}
+
+	return p.respondEphemeralPost(options, "#### Scheduled housecleanings in this channel\n"+
+		"| ID | Schedule | Command | Set up by | Last run |\n"+
+		"|:--|:--|:--|:--|:--|\n"+
+		message), nil
+}
+
+func (p *Plugin) executeScheduleRemove(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	isAdmin := isSysadmin(p, options.userID)
+
+	var userErr userError
+	if err := p.updateSchedules(func(schedules []*channelSchedule) ([]*channelSchedule, error) {
+		for i, schedule := range schedules {
+			if schedule.ID != options.schedule.scheduleID || schedule.ChannelID != options.channelID {
+				continue
+			}
+
+			if schedule.CreatorID != options.userID && !isAdmin {
+				userErr = errors.New("Sorry, you can only remove the schedules you set up")
+				return schedules, nil
+			}
+
+			return append(schedules[:i:i], schedules[i+1:]...), nil
+		}
+
+		userErr = errors.Errorf("There is no schedule `%s` in this channel", options.schedule.scheduleID)
+		return schedules, nil
+	}); err != nil {
+		p.API.LogError("Unable to remove schedule", "err", err)
+		return p.respondEphemeralPost(options, "Error when removing the schedule"), nil
+	}
+
+	if userErr != nil {
+		return p.respondEphemeralPost(options, userErr.Error()), nil
+	}
+
+	return p.respondEphemeralPost(options, fmt.Sprintf("The schedule `%s` has been removed.", options.schedule.scheduleID)), nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScheduleAddArgs(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("HasPermissionTo", "user", mock.Anything).Return(false)
+	api.On("HasPermissionToChannel", "user", "channel", mock.Anything).Return(true)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{MaxPostsPerCommand: 10})
+
+	args := &model.CommandArgs{
+		Command:   `/broom schedule add "0 3 * * *" last 500`,
+		UserId:    "user",
+		ChannelId: "channel",
+		TeamId:    "team",
+	}
+
+	// The quota is checked each time the command runs, not when it is scheduled
+	subcommand, options, userErr := p.parseAndCheckCommandArgs(args)
+	require.Nil(t, userErr)
+	assert.Equal(t, scheduleTrigger, subcommand)
+	assert.Equal(t, "last 500", options.schedule.selector)
+
+	args.Command = `/broom schedule add "0 3 * * *" last many`
+	_, _, userErr = p.parseAndCheckCommandArgs(args)
+	assert.NotNil(t, userErr, "the syntax of the scheduled command is still checked")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	sinceTrigger  = "since"
+	sinceHint     = "[duration|date]"
+	sinceHelpText = "Delete the posts of the channel posted since [duration|date], for example `2h`, `3d` or `2006-01-02 15:04`"
+)
+
+func getSinceAutocompleteData(conf *configuration) *model.AutocompleteData {
+	since := model.NewAutocompleteData(sinceTrigger, sinceHint, sinceHelpText)
+	since.AddTextArgument("A duration like 2h or 3d, or a date like 2006-01-02 15:04", sinceHint, "")
+	addAllNamedTextArgumentsToCmd(since, conf.AskConfirm == askConfirmOptional)
+
+	return since
+}
+
+// parseSinceArgs checks the [duration|date] argument and stores it in options
+func (p *Plugin) parseSinceArgs(args *model.CommandArgs, positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) == 0 {
+		return errors.Errorf("Please specify since when to delete posts: `/broom %s %s`", sinceTrigger, sinceHint)
+	}
+
+	now := time.Now().In(p.getUserLocation(args.UserId))
+	since, err := parseTimeArg(strings.Join(positionalArgs, " "), now)
+	if err != nil {
+		return err
+	}
+
+	options.sinceTime = since.UnixMilli()
+	return nil
+}
+
+func (p *Plugin) executeSince(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if p.shouldConfirmDeletion(options) {
+		p.sendDialogDeleteSince(options)
+	} else {
+		p.deleteSincePostsInChannel(options)
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+func (p *Plugin) sendDialogDeleteSince(options *deletionOptions) {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+
+	introduction := ""
+	if options.filters.hasTextFilter() {
+		postList, appErr := p.getPostsSince(options.channelID, options.sinceTime)
+		if appErr != nil {
+			p.API.LogError("Unable to retrieve posts", "appErr", appErr)
+			p.sendEphemeralPost(options.userID, options.channelID, "Error when retrieving the posts to delete")
+			return
+		}
+		introduction = options.filters.getMatchedCountText(getRelevantPostList(postList))
+	}
+
+	dialog := &model.OpenDialogRequest{
+		TriggerId: options.triggerID,
+		URL:       fmt.Sprintf("%s/plugins/%s%s", *siteURL, manifest.Id, routeDialogDeleteSince),
+		Dialog: model.Dialog{
+			CallbackId: "confirmPostDeletion",
+			Title: fmt.Sprintf(
+				"Do you want to delete all the posts since %s in this channel?",
+				formatTime(options.sinceTime, p.getUserLocation(options.userID)),
+			),
+			IntroductionText: introduction,
+			SubmitLabel:      "Confirm",
+			NotifyOnCancel:   false,
+			State:            options.getDialogState(strconv.FormatInt(options.sinceTime, 10)),
+			Elements: []model.DialogElement{
+				{
+					Type:        "bool",
+					Name:        "deletePinnedPosts",
+					DisplayName: "Delete pinned posts?",
+					HelpText:    "",
+					Default:     strconv.FormatBool(options.optDeletePinnedPosts),
+					Optional:    true,
+				},
+			},
+		},
+	}
+
+	if err := p.API.OpenInteractiveDialog(*dialog); err != nil {
+		p.API.LogError("Failed to open Interactive Dialog", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Failed to open Interactive Dialog")
+	}
+}
+
+func (p *Plugin) deleteSincePostsInChannel(options *deletionOptions) {
+	hasPermissionToDeletePost := canDeletePost(p, options.userID, options.channelID)
+	if !hasPermissionToDeletePost {
+		p.sendEphemeralPost(options.userID, options.channelID, "Sorry, you are not permitted to delete posts")
+		return
+	}
+
+	postList, appErr := p.getPostsSince(options.channelID, options.sinceTime)
+	if appErr != nil {
+		p.API.LogError("Unable to retrieve posts", "appErr", appErr)
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when deleting posts")
+		return
+	}
+
+	p.deletePostsAndReport(postList, options)
+}
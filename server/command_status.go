@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	statusTrigger  = "status"
+	statusHint     = ""
+	statusHelpText = "List your running and recent housecleanings in the channel"
+)
+
+func getStatusAutocompleteData() *model.AutocompleteData {
+	return model.NewAutocompleteData(statusTrigger, statusHint, statusHelpText)
+}
+
+// parseStatusArgs checks that no argument is given
+func (p *Plugin) parseStatusArgs(positionalArgs []string) userError {
+	if len(positionalArgs) > 0 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[0])
+	}
+
+	return nil
+}
+
+func (p *Plugin) executeStatus(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	jobs, err := p.getRecentJobs(options.userID, options.channelID)
+	if err != nil {
+		p.API.LogError("Unable to get recent jobs", "err", err)
+		return p.respondEphemeralPost(options, "Error when retrieving your housecleanings"), nil
+	}
+
+	if len(jobs) == 0 {
+		return p.respondEphemeralPost(options, "You have not broomed this channel recently."), nil
+	}
+
+	location := p.getUserLocation(options.userID)
+	message := "#### Your recent housecleanings in this channel\n" +
+		"| Job | Status | Progress | Deleted | Started |\n" +
+		"|:--|:--|--:|--:|:--|\n"
+
+	for _, job := range jobs {
+		message += fmt.Sprintf(
+			"| `%s` | %s | %d/%d | %d | %s |\n",
+			job.ID, job.Status, job.Processed, job.Total, job.Deleted, formatTime(job.StartAt, location),
+		)
+	}
+
+	return p.respondEphemeralPost(options, message), nil
+}
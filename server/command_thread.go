@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	threadTrigger  = "thread"
+	threadHint     = "[number-of-replies]"
+	threadHelpText = "From the reply box of a thread, delete its replies, or only the last [number-of-replies] ones"
+)
+
+func getThreadAutocompleteData(conf *configuration) *model.AutocompleteData {
+	thread := model.NewAutocompleteData(threadTrigger, threadHint, threadHelpText)
+	thread.AddTextArgument("The number of replies to delete, all of them by default", threadHint, "")
+	thread.AddNamedStaticListArgument(argIncludeRoot, "Also delete the root post, and thus the whole thread", false, []model.AutocompleteListItem{
+		{
+			Item:     "true",
+			HelpText: "Delete the whole thread",
+		}, {
+			Item:     "false",
+			HelpText: "Only delete the replies (default behavior)",
+		},
+	})
+	addAllNamedTextArgumentsToCmd(thread, conf.AskConfirm == askConfirmOptional)
+
+	return thread
+}
+
+// parseThreadArgs checks the thread the command is run from and the optional [number-of-replies] argument,
+// and stores them in options
+func (p *Plugin) parseThreadArgs(args *model.CommandArgs, positionalArgs []string, options *deletionOptions) userError {
+	if args.RootId == "" {
+		return errors.Errorf("Please run `/broom %s` from the reply box of the thread to clean", threadTrigger)
+	}
+
+	if len(positionalArgs) > 1 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[1])
+	}
+
+	if len(positionalArgs) == 1 {
+		numReplies, err := strconv.Atoi(positionalArgs[0])
+		if err != nil {
+			return errors.Errorf("Incorrect argument. [number-of-replies] must be an integer")
+		}
+
+		if numReplies < 1 {
+			return errors.Errorf("You may want to delete at least one reply :wink: ")
+		}
+
+		if options.optIncludeRoot {
+			return errors.Errorf("Deleting the root post deletes the whole thread, so `--%s` can't be used with [number-of-replies]", argIncludeRoot)
+		}
+
+		options.numPost = numReplies
+	}
+
+	options.rootID = args.RootId
+	return nil
+}
+
+func (p *Plugin) executeThread(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if p.shouldConfirmDeletion(options) {
+		p.sendDialogDeleteThread(options)
+	} else {
+		p.deleteThreadPosts(options)
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+// getThreadDescription describes the posts of the thread targeted by options
+func getThreadDescription(options *deletionOptions) string {
+	if options.optIncludeRoot {
+		return "the whole thread"
+	}
+
+	if options.numPost > 0 {
+		return fmt.Sprintf("the last %d repl%s of this thread", options.numPost, getPluralSuffix(options.numPost, "y", "ies"))
+	}
+
+	return "all the replies of this thread"
+}
+
+func (p *Plugin) sendDialogDeleteThread(options *deletionOptions) {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+
+	dialog := &model.OpenDialogRequest{
+		TriggerId: options.triggerID,
+		URL:       fmt.Sprintf("%s/plugins/%s%s", *siteURL, manifest.Id, routeDialogDeleteThread),
+		Dialog: model.Dialog{
+			CallbackId:     "confirmPostDeletion",
+			Title:          fmt.Sprintf("Do you want to delete %s?", getThreadDescription(options)),
+			SubmitLabel:    "Confirm",
+			NotifyOnCancel: false,
+			State:          options.getDialogState(fmt.Sprintf("%s %d %t", options.rootID, options.numPost, options.optIncludeRoot)),
+			Elements: []model.DialogElement{
+				{
+					Type:        "bool",
+					Name:        "deletePinnedPosts",
+					DisplayName: "Delete pinned posts?",
+					HelpText:    "",
+					Default:     strconv.FormatBool(options.optDeletePinnedPosts),
+					Optional:    true,
+				},
+			},
+		},
+	}
+
+	if err := p.API.OpenInteractiveDialog(*dialog); err != nil {
+		p.API.LogError("Failed to open Interactive Dialog", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Failed to open Interactive Dialog")
+	}
+}
+
+// getThreadPosts returns the replies of the thread targeted by options, from the most recent to the oldest one,
+// followed by the root post if requested
+func (p *Plugin) getThreadPosts(options *deletionOptions) (*model.PostList, error) {
+	thread, appErr := p.API.GetPostThread(options.rootID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	root, ok := thread.Posts[options.rootID]
+	if !ok || root.ChannelId != options.channelID {
+		return nil, errors.Errorf("the thread %s does not belong to the channel %s", options.rootID, options.channelID)
+	}
+
+	thread.SortByCreateAt()
+	root.ReplyCount = max(root.ReplyCount, int64(len(thread.Order)-1))
+
+	result := model.NewPostList()
+	for _, postID := range thread.Order {
+		if postID == options.rootID {
+			continue
+		}
+
+		if options.numPost > 0 && len(result.Order) >= options.numPost {
+			break
+		}
+
+		result.AddPost(thread.Posts[postID])
+		result.AddOrder(postID)
+	}
+
+	if options.optIncludeRoot {
+		result.AddPost(root)
+		result.AddOrder(root.Id)
+	}
+
+	return result, nil
+}
+
+func (p *Plugin) deleteThreadPosts(options *deletionOptions) {
+	hasPermissionToDeletePost := canDeletePost(p, options.userID, options.channelID)
+	if !hasPermissionToDeletePost {
+		p.sendEphemeralPost(options.userID, options.channelID, "Sorry, you are not permitted to delete posts")
+		return
+	}
+
+	postList, err := p.getThreadPosts(options)
+	if err != nil {
+		p.API.LogError("Unable to retrieve posts", "err", err)
+		p.sendEphemeralPost(options.userID, options.channelID, "Error when deleting posts")
+		return
+	}
+
+	p.deletePostsAndReport(postList, options)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseThreadArgs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		rootID        string
+		args          []string
+		includeRoot   bool
+		expectedNum   int
+		expectedError bool
+	}{
+		"all replies":                     {rootID: "root", args: []string{}},
+		"last replies":                    {rootID: "root", args: []string{"3"}, expectedNum: 3},
+		"whole thread":                    {rootID: "root", args: []string{}, includeRoot: true},
+		"not in a thread":                 {rootID: "", args: []string{}, expectedError: true},
+		"not a number":                    {rootID: "root", args: []string{"three"}, expectedError: true},
+		"zero replies":                    {rootID: "root", args: []string{"0"}, expectedError: true},
+		"too many arguments":              {rootID: "root", args: []string{"3", "4"}, expectedError: true},
+		"last replies and the whole root": {rootID: "root", args: []string{"3"}, includeRoot: true, expectedError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := &Plugin{}
+			options := &deletionOptions{optIncludeRoot: tc.includeRoot}
+
+			userErr := p.parseThreadArgs(&model.CommandArgs{RootId: tc.rootID}, tc.args, options)
+			if tc.expectedError {
+				assert.NotNil(t, userErr)
+				return
+			}
+
+			require.Nil(t, userErr)
+			assert.Equal(t, tc.rootID, options.rootID)
+			assert.Equal(t, tc.expectedNum, options.numPost)
+		})
+	}
+}
+
+func TestGetThreadPosts(t *testing.T) {
+	// The thread is given in any order, as by the server
+	getThread := func() *model.PostList {
+		thread := model.NewPostList()
+		for _, post := range []*model.Post{
+			{Id: "reply2", ChannelId: "channel", RootId: "root", CreateAt: 3},
+			{Id: "root", ChannelId: "channel", CreateAt: 1},
+			{Id: "reply3", ChannelId: "channel", RootId: "root", CreateAt: 4},
+			{Id: "reply1", ChannelId: "channel", RootId: "root", CreateAt: 2},
+		} {
+			thread.AddPost(post)
+			thread.AddOrder(post.Id)
+		}
+		return thread
+	}
+
+	for name, tc := range map[string]struct {
+		options       deletionOptions
+		expected      []string
+		expectedError bool
+	}{
+		"all replies": {
+			options:  deletionOptions{channelID: "channel", rootID: "root"},
+			expected: []string{"reply3", "reply2", "reply1"},
+		},
+		"last replies": {
+			options:  deletionOptions{channelID: "channel", rootID: "root", numPost: 2},
+			expected: []string{"reply3", "reply2"},
+		},
+		"more replies than the thread has": {
+			options:  deletionOptions{channelID: "channel", rootID: "root", numPost: 10},
+			expected: []string{"reply3", "reply2", "reply1"},
+		},
+		"whole thread": {
+			options:  deletionOptions{channelID: "channel", rootID: "root", optIncludeRoot: true},
+			expected: []string{"reply3", "reply2", "reply1", "root"},
+		},
+		"thread of another channel": {
+			options:       deletionOptions{channelID: "other", rootID: "root"},
+			expectedError: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			thread := getThread()
+			api.On("GetPostThread", "root").Return(thread, nil).Once()
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+
+			postList, err := p.getThreadPosts(&tc.options)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, postList.Order)
+			// The reply count is known from the thread, so that the root isn't kept when all its replies are deleted
+			assert.Equal(t, int64(3), thread.Posts["root"].ReplyCount)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	ttlTrigger  = "ttl"
+	ttlHint     = "[duration|off]"
+	ttlHelpText = "Make the new posts of the channel disappear after [duration], like `10m` or `1d`"
+
+	ttlOff = "off"
+
+	// minTTL is the shortest TTL, because the expired posts are deleted every minute
+	minTTL = time.Minute
+)
+
+// ttlOptions contains the arguments of the ttl subcommand
+type ttlOptions struct {
+	show     bool
+	duration time.Duration
+}
+
+func getTTLAutocompleteData() *model.AutocompleteData {
+	ttl := model.NewAutocompleteData(ttlTrigger, ttlHint, ttlHelpText)
+	ttl.AddTextArgument("How long the new posts are kept, like `10m` or `1d`, or `off`. Leave empty to show the current TTL", ttlHint, "")
+
+	return ttl
+}
+
+// parseTTLArgs checks the optional [duration] argument and stores it in options
+func (p *Plugin) parseTTLArgs(positionalArgs []string, options *deletionOptions) userError {
+	if len(positionalArgs) > 1 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[1])
+	}
+
+	options.ttl = &ttlOptions{}
+
+	if len(positionalArgs) == 0 {
+		options.ttl.show = true
+		return nil
+	}
+
+	if positionalArgs[0] == ttlOff {
+		return nil
+	}
+
+	duration, err := parseDuration(positionalArgs[0])
+	if err != nil {
+		return errors.Errorf("Invalid duration `%s`, it should be like `10m`, `2h` or `1d`", positionalArgs[0])
+	}
+
+	if duration < minTTL {
+		return errors.Errorf("The posts should be kept at least %s", minTTL)
+	}
+
+	options.ttl.duration = duration
+	return nil
+}
+
+func (p *Plugin) executeTTL(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if options.ttl.show {
+		return p.executeTTLShow(options)
+	}
+
+	// The TTL deletes the posts of everyone
+	if !canDeleteOthersPosts(p, options.userID, options.channelID) {
+		return p.respondEphemeralPost(options, "Sorry, only the users permitted to delete the posts of others can manage the TTL of the channel"), nil
+	}
+
+	if options.ttl.duration == 0 {
+		if err := p.deleteChannelTTL(options.channelID); err != nil {
+			p.API.LogError("Unable to delete channel TTL", "err", err)
+			return p.respondEphemeralPost(options, "Error when removing the TTL"), nil
+		}
+
+		return p.respondEphemeralPost(options, "The new posts of this channel will not disappear anymore. The posts already posted will still disappear when their TTL is over."), nil
+	}
+
+	if err := p.saveChannelTTL(&channelTTL{
+		ChannelID:  options.channelID,
+		CreatorID:  options.userID,
+		TTLSeconds: int64(options.ttl.duration / time.Second),
+		CreateAt:   model.GetMillis(),
+	}); err != nil {
+		p.API.LogError("Unable to save channel TTL", "err", err)
+		return p.respondEphemeralPost(options, "Error when saving the TTL"), nil
+	}
+
+	return p.respondEphemeralPost(options, fmt.Sprintf(
+		"From now on, the new posts of this channel will disappear %s after being posted, pinned or not.",
+		options.ttl.duration,
+	)), nil
+}
+
+func (p *Plugin) executeTTLShow(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	ttl, err := p.getChannelTTL(options.channelID)
+	if err != nil {
+		p.API.LogError("Unable to get channel TTL", "err", err)
+		return p.respondEphemeralPost(options, "Error when retrieving the TTL"), nil
+	}
+
+	if ttl == nil {
+		return p.respondEphemeralPost(options, "The posts of this channel don't disappear."), nil
+	}
+
+	return p.respondEphemeralPost(options, fmt.Sprintf(
+		"The new posts of this channel disappear %s after being posted. Set up by %s.",
+		time.Duration(ttl.TTLSeconds)*time.Second, p.getUserMention(ttl.CreatorID),
+	)), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	undoTrigger  = "undo"
+	undoHint     = ""
+	undoHelpText = "Restore the posts you deleted in the channel during the last broom, if done recently"
+)
+
+func getUndoAutocompleteData() *model.AutocompleteData {
+	return model.NewAutocompleteData(undoTrigger, undoHint, undoHelpText)
+}
+
+// parseUndoArgs checks that no argument is given
+func (p *Plugin) parseUndoArgs(positionalArgs []string) userError {
+	if len(positionalArgs) > 0 {
+		return errors.Errorf("Invalid argument `%s`", positionalArgs[0])
+	}
+
+	return nil
+}
+
+func (p *Plugin) executeUndo(options *deletionOptions) (*model.CommandResponse, *model.AppError) {
+	if p.getUndoGracePeriod() <= 0 {
+		return p.respondEphemeralPost(options, "Restoring deleted posts is disabled on this server."), nil
+	}
+
+	snapshot, err := p.getUndoSnapshot(options.userID, options.channelID)
+	if err != nil {
+		p.API.LogError("Unable to get undo snapshot", "err", err)
+		return p.respondEphemeralPost(options, "Error when restoring posts"), nil
+	}
+
+	if snapshot == nil {
+		return p.respondEphemeralPost(options, "There are no recently deleted posts to restore in this channel."), nil
+	}
+
+	posts, err := p.getUndoSnapshotPosts(snapshot)
+	if err != nil {
+		p.API.LogError("Unable to get the posts of the undo snapshot", "err", err)
+		return p.respondEphemeralPost(options, "Error when restoring posts"), nil
+	}
+
+	// Delete the snapshot first so the posts can't be restored twice
+	if err = p.client.KV.Delete(getUndoSnapshotKey(options.userID, options.channelID)); err != nil {
+		p.API.LogError("Unable to delete undo snapshot", "err", err)
+		return p.respondEphemeralPost(options, "Error when restoring posts"), nil
+	}
+
+	beginningPost := p.sendEphemeralPost(options.userID, options.channelID, "Restoring posts, please wait...")
+
+	// The copied files are attached to the restored posts, so only the chunks are deleted
+	createdPostIDs, numFailed := p.recreatePosts(posts, options.channelID, nil)
+	numCreated := len(createdPostIDs)
+	p.deleteUndoChunks(snapshot)
+
+	message := ""
+	if numFailed > 0 {
+		message += fmt.Sprintf(
+			"Because of a technical error, %d post%s could not be restored.\n",
+			numFailed, getPluralChar(numFailed),
+		)
+	}
+	message += fmt.Sprintf("Successfully restored %d post%s.", numCreated, getPluralChar(numCreated))
+
+	beginningPost.Message = message
+	p.API.UpdateEphemeralPost(options.userID, beginningPost)
+
+	return &model.CommandResponse{}, nil
+}
@@ -12,6 +12,14 @@ const (
 	askConfirmNever    = "never"
 )
 
+// Roles allowed to use /broom, from the most to the least restrictive
+const (
+	allowedRolesSystemAdmin  = "system_admin"
+	allowedRolesTeamAdmin    = "team_admin"
+	allowedRolesChannelAdmin = "channel_admin"
+	allowedRolesAll          = "all"
+)
+
 // configuration captures the plugin's external configuration as exposed in the Mattermost server
 // configuration, as well as values computed from the configuration. Any public fields will be
 // deserialized from the Mattermost server configuration in OnConfigurationChange.
@@ -24,8 +32,36 @@ const (
 // If you add non-reference types to your configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type configuration struct {
+	AllowedRoles           string
+	AskConfirm             string
+	ArchiveChannelID       string
+	UndoGracePeriodMinutes int
+	ProtectedChannelIDs    string
+	AllowedChannelIDs      string
+	ProtectedAuthors       string
+	MaxPostsPerCommand     int
+	MaxPostsPerUserPerDay  int
+	ApprovalThreshold      int
+	ApproversChannelID     string
+	ApprovalExpiryHours    int
+	AuditChannelID         string
+
+	// Deprecated: replaced by AllowedRoles, only read when AllowedRoles is not set
 	RestrictToSysadmins bool
-	AskConfirm          string
+}
+
+// getAllowedRoles returns the roles allowed to use /broom, migrating the former RestrictToSysadmins setting
+func (c *configuration) getAllowedRoles() string {
+	if c.AllowedRoles == "" && c.RestrictToSysadmins {
+		return allowedRolesSystemAdmin
+	}
+
+	switch c.AllowedRoles {
+	case allowedRolesSystemAdmin, allowedRolesTeamAdmin, allowedRolesChannelAdmin:
+		return c.AllowedRoles
+	}
+
+	return allowedRolesAll
 }
 
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
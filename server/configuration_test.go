@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestGetAllowedRoles(t *testing.T) {
+	for name, tc := range map[string]struct {
+		conf     configuration
+		expected string
+	}{
+		"not set":                    {conf: configuration{}, expected: allowedRolesAll},
+		"channel admins":             {conf: configuration{AllowedRoles: allowedRolesChannelAdmin}, expected: allowedRolesChannelAdmin},
+		"former restriction":         {conf: configuration{RestrictToSysadmins: true}, expected: allowedRolesSystemAdmin},
+		"set over former":            {conf: configuration{AllowedRoles: allowedRolesTeamAdmin, RestrictToSysadmins: true}, expected: allowedRolesTeamAdmin},
+		"unknown value":              {conf: configuration{AllowedRoles: "moderators"}, expected: allowedRolesAll},
+		"any member over restricted": {conf: configuration{AllowedRoles: allowedRolesAll, RestrictToSysadmins: true}, expected: allowedRolesAll},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if allowedRoles := tc.conf.getAllowedRoles(); allowedRoles != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, allowedRoles)
+			}
+		})
+	}
+}
@@ -7,7 +7,14 @@ import (
 )
 
 const (
-	routeDialogDeleteLast = "/dialog/deletion"
+	routeDialogDeleteLast    = "/dialog/deletion"
+	routeDialogDeleteSince   = "/dialog/deletion/since"
+	routeDialogDeleteBetween = "/dialog/deletion/between"
+	routeDialogDeleteFrom    = "/dialog/deletion/from"
+	routeDialogDeleteThread  = "/dialog/deletion/thread"
+	routeDialogMove          = "/dialog/move"
+	routeAutocompleteUsers   = "/autocomplete/users"
+	routeApprovalAction      = "/approval/action"
 )
 
 // ServeHTTP allows the plugin to implement the http.Handler interface. Requests destined for the
@@ -16,6 +23,22 @@ func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Req
 	switch r.URL.Path {
 	case routeDialogDeleteLast:
 		p.dialogDeleteLast(w, r)
+	case routeDialogDeleteSince:
+		p.dialogDeleteSince(w, r)
+	case routeDialogDeleteBetween:
+		p.dialogDeleteBetween(w, r)
+	case routeDialogDeleteFrom:
+		p.dialogDeleteFrom(w, r)
+	case routeDialogDeleteThread:
+		p.dialogDeleteThread(w, r)
+	case routeDialogMove:
+		p.dialogMove(w, r)
+
+	case routeAutocompleteUsers:
+		p.autocompleteUsers(w, r)
+
+	case routeApprovalAction:
+		p.approvalAction(w, r)
 
 	default:
 		http.NotFound(w, r)
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// approvalAction handles the Approve and Reject buttons of the approval requests
+func (p *Plugin) approvalAction(w http.ResponseWriter, r *http.Request) {
+	// The user ID of the request body can't be trusted, unlike this header set by the server
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request *model.PostActionIntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request == nil {
+		p.API.LogWarn("failed to decode PostActionIntegrationRequest")
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	requestID, _ := request.Context["request_id"].(string)
+	action, _ := request.Context["action"].(string)
+	if action != approvalActionApprove && action != approvalActionReject {
+		http.Error(w, "invalid action", http.StatusBadRequest)
+		return
+	}
+
+	pending, err := p.getApprovalRequest(requestID)
+	if err != nil {
+		p.API.LogError("Unable to get approval request", "err", err)
+		p.writePostActionResponse(w, "Error when reviewing the request")
+		return
+	}
+
+	if pending == nil {
+		p.writePostActionResponse(w, "This request has already been reviewed, or has expired.")
+		return
+	}
+
+	if !p.canReviewApproval(userID, pending) {
+		p.writePostActionResponse(w, "Sorry, you are not permitted to review this request")
+		return
+	}
+
+	pending, err = p.takeApprovalRequest(requestID)
+	if err != nil {
+		p.API.LogError("Unable to take approval request", "err", err)
+		p.writePostActionResponse(w, "Error when reviewing the request")
+		return
+	}
+
+	if pending == nil {
+		p.writePostActionResponse(w, "This request has already been reviewed, or has expired.")
+		return
+	}
+
+	if action == approvalActionApprove {
+		p.updateApprovalPost(pending, fmt.Sprintf("Approved by %s.", p.getUserMention(userID)))
+		go p.runApprovedDeletion(pending, userID)
+	} else {
+		p.updateApprovalPost(pending, fmt.Sprintf("Rejected by %s.", p.getUserMention(userID)))
+		p.sendEphemeralPost(pending.UserID, pending.ChannelID, fmt.Sprintf(
+			"%s rejected your housecleaning of %d posts, so nothing has been deleted.",
+			p.getUserMention(userID), pending.NumPosts,
+		))
+	}
+
+	p.writePostActionResponse(w, "")
+}
+
+// writePostActionResponse responds to an interactive message button, with an ephemeral message if not empty
+func (p *Plugin) writePostActionResponse(w http.ResponseWriter, ephemeralText string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&model.PostActionIntegrationResponse{EphemeralText: ephemeralText}); err != nil {
+		p.API.LogWarn("Failed to write post action response", "err", err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApprovalAction(t *testing.T) {
+	getRequest := func(expireAt int64) []byte {
+		data, err := json.Marshal(&approvalRequest{
+			ID:             "request",
+			UserID:         "user",
+			ChannelID:      "channel",
+			NumPosts:       12,
+			ApprovalPostID: "approvalPost",
+			ExpireAt:       expireAt,
+		})
+		require.NoError(t, err)
+		return data
+	}
+	now := model.GetMillis()
+
+	for name, tc := range map[string]struct {
+		reviewerID      string
+		action          string
+		kvValue         []byte
+		removed         bool
+		expectedText    string
+		expectedOutcome string
+		expectedMessage string
+	}{
+		"approved": {
+			reviewerID:      "reviewer",
+			action:          approvalActionApprove,
+			kvValue:         getRequest(now + 60000),
+			removed:         true,
+			expectedOutcome: "Approved by @reviewer.",
+			// The requester lost the permission to delete posts meanwhile
+			expectedMessage: "Your housecleaning has been approved, but you are not permitted to delete posts anymore",
+		},
+		"rejected": {
+			reviewerID:      "reviewer",
+			action:          approvalActionReject,
+			kvValue:         getRequest(now + 60000),
+			removed:         true,
+			expectedOutcome: "Rejected by @reviewer.",
+			expectedMessage: "@reviewer rejected your housecleaning of 12 posts, so nothing has been deleted.",
+		},
+		"reviewed by the requester": {
+			reviewerID:   "user",
+			action:       approvalActionApprove,
+			kvValue:      getRequest(now + 60000),
+			expectedText: "Sorry, you are not permitted to review this request",
+		},
+		"reviewed meanwhile": {
+			reviewerID:   "reviewer",
+			action:       approvalActionApprove,
+			kvValue:      getRequest(now + 60000),
+			removed:      false,
+			expectedText: "This request has already been reviewed, or has expired.",
+		},
+		"expired": {
+			reviewerID:   "reviewer",
+			action:       approvalActionApprove,
+			kvValue:      getRequest(now - 1000),
+			expectedText: "This request has already been reviewed, or has expired.",
+		},
+		"already reviewed": {
+			reviewerID:   "reviewer",
+			action:       approvalActionReject,
+			expectedText: "This request has already been reviewed, or has expired.",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			messageSent := make(chan struct{})
+
+			api := &plugintest.API{}
+			api.On("KVGet", getApprovalKey("request")).Return(tc.kvValue, nil)
+			value, options := isApprovalTaken(tc.kvValue)
+			api.On("KVSetWithOptions", getApprovalKey("request"), value, options).Return(tc.removed, nil).Maybe()
+			api.On("GetChannel", "channel").Return(&model.Channel{Id: "channel", TeamId: "team"}, nil).Maybe()
+			api.On("HasPermissionTo", "reviewer", model.PermissionDeleteOthersPosts).Return(true).Maybe()
+			api.On("HasPermissionTo", "user", mock.Anything).Return(false).Maybe()
+			api.On("HasPermissionToChannel", "user", "channel", mock.Anything).Return(false).Maybe()
+			api.On("GetUser", "reviewer").Return(&model.User{Id: "reviewer", Username: "reviewer"}, nil).Maybe()
+			if tc.expectedOutcome != "" {
+				api.On("GetPost", "approvalPost").Return(&model.Post{Id: "approvalPost", Message: "Request"}, nil).Once()
+				api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+					return post.Id == "approvalPost" && strings.HasSuffix(post.Message, tc.expectedOutcome)
+				})).Return(&model.Post{}, nil).Once()
+				api.On("SendEphemeralPost", "user", mock.MatchedBy(func(post *model.Post) bool {
+					return post.ChannelId == "channel" && post.Message == tc.expectedMessage
+				})).Return(&model.Post{}).Once().Run(func(mock.Arguments) { close(messageSent) })
+			}
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+
+			body, err := json.Marshal(&model.PostActionIntegrationRequest{
+				UserId:  tc.reviewerID,
+				Context: map[string]any{"request_id": "request", "action": tc.action},
+			})
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodPost, routeApprovalAction, bytes.NewReader(body))
+			r.Header.Set("Mattermost-User-ID", tc.reviewerID)
+			w := httptest.NewRecorder()
+
+			p.approvalAction(w, r)
+
+			var response *model.PostActionIntegrationResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.Equal(t, tc.expectedText, response.EphemeralText)
+
+			if tc.expectedOutcome != "" {
+				// The approved deletion runs in the background
+				select {
+				case <-messageSent:
+				case <-time.After(time.Second):
+					t.Fatal("the requester has not been notified")
+				}
+			} else {
+				api.AssertNotCalled(t, "UpdatePost", mock.Anything)
+			}
+			api.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// maxAutocompleteItems is the maximum number of suggestions returned by the autocomplete routes
+const maxAutocompleteItems = 25
+
+// autocompleteUsers suggests the members of the channel matching what the user typed
+func (p *Plugin) autocompleteUsers(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	channelID := query.Get("channel_id")
+
+	// Don't list the members of a channel the user can't read
+	if channelID == "" || !p.API.HasPermissionToChannel(userID, channelID, model.PermissionReadChannel) {
+		http.Error(w, "not authorized", http.StatusForbidden)
+		return
+	}
+
+	users, appErr := p.API.SearchUsers(&model.UserSearch{
+		Term:        strings.TrimPrefix(query.Get("user_input"), "@"),
+		InChannelId: channelID,
+		Limit:       maxAutocompleteItems,
+	})
+	if appErr != nil {
+		p.API.LogError("Unable to search users", "appErr", appErr)
+		http.Error(w, "unable to search users", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]model.AutocompleteListItem, 0, len(users))
+	for _, user := range users {
+		items = append(items, model.AutocompleteListItem{
+			Item:     "@" + user.Username,
+			HelpText: user.GetDisplayName(model.ShowFullName),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		p.API.LogWarn("Failed to write autocomplete response", "err", err)
+	}
+}
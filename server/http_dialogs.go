@@ -2,28 +2,90 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/mattermost/mattermost/server/public/model"
 )
 
-func (p *Plugin) dialogDeleteLast(w http.ResponseWriter, r *http.Request) {
+// decodeDialogSubmission decodes the submitted dialog and its state, and returns the ID of the submitting user.
+// It writes the response and returns nil if the dialog should not be processed further
+func (p *Plugin) decodeDialogSubmission(w http.ResponseWriter, r *http.Request) (*model.SubmitDialogRequest, *dialogState, string) {
+	// The user ID of the request body can't be trusted, unlike this header set by the server
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return nil, nil, ""
+	}
+
 	var request *model.SubmitDialogRequest
 	decodeErr := json.NewDecoder(r.Body).Decode(&request)
 	if decodeErr != nil || request == nil {
 		p.API.LogWarn("failed to decode SubmitDialogRequest")
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		return
+		return nil, nil, ""
+	}
+
+	if request.UserId != userID {
+		http.Error(w, "not authorized", http.StatusForbidden)
+		return nil, nil, ""
 	}
 
 	//nolint:misspell
 	if request.Cancelled {
 		w.WriteHeader(http.StatusOK)
+		return nil, nil, ""
+	}
+
+	if !isAllowedToBroom(p, userID, request.TeamId, request.ChannelId) {
+		http.Error(w, "not authorized", http.StatusForbidden)
+		return nil, nil, ""
+	}
+
+	state, err := parseDialogState(request.State)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, nil, ""
+	}
+
+	// The channel may have been protected since the dialog was opened
+	if p.isChannelProtected(request.ChannelId) {
+		p.writeDialogError(w, messageChannelProtected)
+		return nil, nil, ""
+	}
+
+	return request, state, userID
+}
+
+// writeDialogError responds to a submitted dialog with an error, shown in the dialog which stays open
+func (p *Plugin) writeDialogError(w http.ResponseWriter, dialogError string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&model.SubmitDialogResponse{Error: dialogError}); err != nil {
+		p.API.LogWarn("Failed to write dialog response", "err", err)
+	}
+}
+
+// getDialogDeletionOptions rebuilds the deletion options of the user from the submitted dialog
+func (p *Plugin) getDialogDeletionOptions(userID string, request *model.SubmitDialogRequest, state *dialogState) *deletionOptions {
+	return &deletionOptions{
+		channelID:             request.ChannelId,
+		userID:                userID,
+		filters:               state.Filters,
+		archiveFormat:         state.ArchiveFormat,
+		command:               state.Command,
+		optDeletePinnedPosts:  request.Submission["deletePinnedPosts"] == true,
+		permDeleteOthersPosts: canDeleteOthersPosts(p, userID, request.ChannelId),
+	}
+}
+
+func (p *Plugin) dialogDeleteLast(w http.ResponseWriter, r *http.Request) {
+	request, state, userID := p.decodeDialogSubmission(w, r)
+	if request == nil {
 		return
 	}
 
-	numPostToDelete, err := strconv.Atoi(request.State)
+	numPostToDelete, err := strconv.Atoi(state.Value)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -31,11 +93,113 @@ func (p *Plugin) dialogDeleteLast(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 
-	p.deleteLastPostsInChannel(&deletionOptions{
-		channelID:             request.ChannelId,
-		userID:                request.UserId,
-		numPost:               numPostToDelete,
-		optDeletePinnedPosts:  request.Submission["deletePinnedPost"] == "true",
-		permDeleteOthersPosts: canDeleteOthersPosts(p, request.UserId, request.ChannelId),
-	})
+	options := p.getDialogDeletionOptions(userID, request, state)
+	options.numPost = numPostToDelete
+	p.deleteLastPostsInChannel(options)
+}
+
+func (p *Plugin) dialogDeleteSince(w http.ResponseWriter, r *http.Request) {
+	request, state, userID := p.decodeDialogSubmission(w, r)
+	if request == nil {
+		return
+	}
+
+	sinceTime, err := strconv.ParseInt(state.Value, 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	options := p.getDialogDeletionOptions(userID, request, state)
+	options.sinceTime = sinceTime
+	p.deleteSincePostsInChannel(options)
+}
+
+func (p *Plugin) dialogDeleteBetween(w http.ResponseWriter, r *http.Request) {
+	request, state, userID := p.decodeDialogSubmission(w, r)
+	if request == nil {
+		return
+	}
+
+	var sinceTime, untilTime int64
+	if _, err := fmt.Sscanf(state.Value, "%d %d", &sinceTime, &untilTime); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	options := p.getDialogDeletionOptions(userID, request, state)
+	options.sinceTime = sinceTime
+	options.untilTime = untilTime
+	p.deleteBetweenPostsInChannel(options)
+}
+
+func (p *Plugin) dialogDeleteFrom(w http.ResponseWriter, r *http.Request) {
+	request, state, userID := p.decodeDialogSubmission(w, r)
+	if request == nil {
+		return
+	}
+
+	if !model.IsValidId(state.Value) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	options := p.getDialogDeletionOptions(userID, request, state)
+	options.fromPostID = state.Value
+	p.deleteFromPostsInChannel(options)
+}
+
+func (p *Plugin) dialogDeleteThread(w http.ResponseWriter, r *http.Request) {
+	request, state, userID := p.decodeDialogSubmission(w, r)
+	if request == nil {
+		return
+	}
+
+	var rootID string
+	var numReplies int
+	var includeRoot bool
+	if _, err := fmt.Sscanf(state.Value, "%s %d %t", &rootID, &numReplies, &includeRoot); err != nil || !model.IsValidId(rootID) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	options := p.getDialogDeletionOptions(userID, request, state)
+	options.rootID = rootID
+	options.numPost = numReplies
+	options.optIncludeRoot = includeRoot
+	p.deleteThreadPosts(options)
+}
+
+func (p *Plugin) dialogMove(w http.ResponseWriter, r *http.Request) {
+	request, state, userID := p.decodeDialogSubmission(w, r)
+	if request == nil {
+		return
+	}
+
+	var numPostToMove int
+	var moveChannelID string
+	if _, err := fmt.Sscanf(state.Value, "%d %s", &numPostToMove, &moveChannelID); err != nil || !model.IsValidId(moveChannelID) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if !p.API.HasPermissionToChannel(userID, moveChannelID, model.PermissionCreatePost) {
+		p.sendEphemeralPost(userID, request.ChannelId, "You are not permitted to post in the target channel")
+		return
+	}
+
+	options := p.getDialogDeletionOptions(userID, request, state)
+	options.numPost = numPostToMove
+	options.moveChannelID = moveChannelID
+	p.moveLastPostsInChannel(options)
 }
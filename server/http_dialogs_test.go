@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeDialogSubmission(t *testing.T) {
+	protected, err := json.Marshal(&protectedChannel{ChannelID: "protected", UserID: "admin"})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		channelID     string
+		state         string
+		expectedCode  int
+		expectedError string
+		expectedValid bool
+	}{
+		"valid submission": {
+			channelID:     "channel",
+			state:         `{"value":"10"}`,
+			expectedCode:  http.StatusOK,
+			expectedValid: true,
+		},
+		"protected channel": {
+			channelID:     "protected",
+			state:         `{"value":"10"}`,
+			expectedCode:  http.StatusOK,
+			expectedError: messageChannelProtected,
+		},
+		"invalid archive format": {
+			channelID:    "channel",
+			state:        `{"value":"10","archive_format":"../../etc"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		"invalid state": {
+			channelID:    "channel",
+			state:        `not json`,
+			expectedCode: http.StatusBadRequest,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("KVGet", getProtectedChannelKey("channel")).Return(nil, nil)
+			api.On("KVGet", getProtectedChannelKey("protected")).Return(protected, nil)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+
+			body, err := json.Marshal(&model.SubmitDialogRequest{UserId: "user", ChannelId: tc.channelID, State: tc.state})
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodPost, "/dialog", bytes.NewReader(body))
+			r.Header.Set("Mattermost-User-ID", "user")
+			w := httptest.NewRecorder()
+
+			request, state, userID := p.decodeDialogSubmission(w, r)
+			assert.Equal(t, tc.expectedValid, request != nil && state != nil && userID == "user")
+
+			// The response is only written by decodeDialogSubmission if the dialog is not processed further
+			if tc.expectedValid {
+				return
+			}
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedError != "" {
+				var response *model.SubmitDialogResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				assert.Equal(t, tc.expectedError, response.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	jobStatusRunning   = "running"
+	jobStatusDone      = "done"
+	jobStatusFailed    = "failed"
+	jobStatusCancelled = "cancelled"
+
+	// jobProgressInterval is the minimum interval between two updates of the progress of a job
+	jobProgressInterval = 2 * time.Second
+
+	// jobCancelCheckInterval is the minimum interval between two checks of the cancellation of a job
+	jobCancelCheckInterval = time.Second
+
+	// jobRetention is for how long a job is kept in the KV store
+	jobRetention = 7 * 24 * time.Hour
+
+	// progressBarWidth is the number of characters of the progress bar
+	progressBarWidth = 20
+
+	// maxJobsPerIndex is the number of recent jobs kept for each user in each channel
+	maxJobsPerIndex = 10
+
+	// jobHeartbeatInterval is the interval between two heartbeats of a running job
+	jobHeartbeatInterval = 30 * time.Second
+
+	// jobStaleTimeout is how long a running job can go without a heartbeat before being considered failed,
+	// for example because the server running it crashed
+	jobStaleTimeout = 5 * time.Minute
+)
+
+// deletionJob tracks a deletion running in the background
+type deletionJob struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	ChannelID   string `json:"channel_id"`
+	Status      string `json:"status"`
+	Total       int    `json:"total"`
+	Processed   int    `json:"processed"`
+	Deleted     int    `json:"deleted"`
+	StartAt     int64  `json:"start_at"`
+	HeartbeatAt int64  `json:"heartbeat_at"`
+	EndAt       int64  `json:"end_at"`
+	Report      string `json:"report"`
+}
+
+func getJobKey(jobID string) string {
+	return kvJobPrefix + jobID
+}
+
+func getJobCancelKey(jobID string) string {
+	return kvJobCancelPrefix + jobID
+}
+
+func getJobIndexKey(userID string, channelID string) string {
+	return kvJobIndexPrefix + userID + "_" + channelID
+}
+
+func (p *Plugin) saveJob(job *deletionJob) {
+	job.HeartbeatAt = model.GetMillis()
+	if _, err := p.client.KV.Set(getJobKey(job.ID), job, pluginapi.SetExpiry(jobRetention)); err != nil {
+		p.API.LogError("Unable to save job", "JobID", job.ID, "err", err)
+	}
+}
+
+// getJob returns the job, or nil if it has expired. A stale job is marked as failed
+func (p *Plugin) getJob(jobID string) (*deletionJob, error) {
+	var job *deletionJob
+	if err := p.client.KV.Get(getJobKey(jobID), &job); err != nil {
+		return nil, err
+	}
+
+	if job != nil && job.isStale(model.GetMillis()) {
+		job.Status = jobStatusFailed
+		job.EndAt = job.HeartbeatAt
+		job.Report = "The housecleaning stopped unexpectedly, probably because the server running it was restarted."
+		p.saveJob(job)
+	}
+
+	return job, nil
+}
+
+// isStale tells if the job is still running but has not given a heartbeat for too long
+func (job *deletionJob) isStale(now int64) bool {
+	lastSign := job.HeartbeatAt
+	if lastSign == 0 {
+		lastSign = job.StartAt
+	}
+
+	return job.Status == jobStatusRunning && now-lastSign > jobStaleTimeout.Milliseconds()
+}
+
+// keepJobAlive updates the heartbeat of the job periodically, until stop is closed.
+// Only the heartbeat is updated, so that the state saved by the job itself is kept
+func (p *Plugin) keepJobAlive(jobID string, stop <-chan struct{}) {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := p.client.KV.SetAtomicWithRetries(getJobKey(jobID), func(oldValue []byte) (any, error) {
+				var job *deletionJob
+				if err := json.Unmarshal(oldValue, &job); err != nil {
+					return nil, err
+				}
+
+				job.HeartbeatAt = model.GetMillis()
+				return job, nil
+			}); err != nil {
+				p.API.LogWarn("Unable to update the heartbeat of the job", "JobID", jobID, "err", err)
+			}
+		}
+	}
+}
+
+// addJobToIndex records the job in the recent jobs of its user in its channel
+func (p *Plugin) addJobToIndex(job *deletionJob) error {
+	return p.client.KV.SetAtomicWithRetries(getJobIndexKey(job.UserID, job.ChannelID), func(oldValue []byte) (any, error) {
+		var jobIDs []string
+		if len(oldValue) > 0 {
+			if err := json.Unmarshal(oldValue, &jobIDs); err != nil {
+				return nil, err
+			}
+		}
+
+		jobIDs = append([]string{job.ID}, jobIDs...)
+		if len(jobIDs) > maxJobsPerIndex {
+			jobIDs = jobIDs[:maxJobsPerIndex]
+		}
+
+		return jobIDs, nil
+	})
+}
+
+// getRecentJobs returns the recent jobs of the user in the channel, from the most recent one
+func (p *Plugin) getRecentJobs(userID string, channelID string) ([]*deletionJob, error) {
+	var jobIDs []string
+	if err := p.client.KV.Get(getJobIndexKey(userID, channelID), &jobIDs); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*deletionJob, 0, len(jobIDs))
+	for _, jobID := range jobIDs {
+		job, err := p.getJob(jobID)
+		if err != nil {
+			return nil, err
+		}
+
+		if job != nil { // the job may have expired
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
+// requestJobCancellation asks the job to stop. The job checks it periodically, whichever server runs it
+func (p *Plugin) requestJobCancellation(jobID string) error {
+	_, err := p.client.KV.Set(getJobCancelKey(jobID), true, pluginapi.SetExpiry(jobRetention))
+	return err
+}
+
+func (p *Plugin) isJobCancellationRequested(jobID string) bool {
+	var cancelled bool
+	if err := p.client.KV.Get(getJobCancelKey(jobID), &cancelled); err != nil {
+		p.API.LogError("Unable to check job cancellation", "JobID", jobID, "err", err)
+		return false
+	}
+
+	return cancelled
+}
+
+// progressString describes the progress of the job with a progress bar and the estimated remaining time
+func (job *deletionJob) progressString() string {
+	if job.Total == 0 {
+		return messageBeginning
+	}
+
+	ratio := float64(job.Processed) / float64(job.Total)
+	filled := int(ratio * progressBarWidth)
+	progress := fmt.Sprintf(
+		"Housecleaning in progress: `[%s%s]` %d%% (%d/%d posts)",
+		strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled),
+		int(ratio*100), job.Processed, job.Total,
+	)
+
+	if job.Processed > 0 && job.Processed < job.Total {
+		elapsed := time.Since(time.UnixMilli(job.StartAt))
+		remaining := time.Duration(float64(elapsed) / ratio * (1 - ratio))
+		progress += fmt.Sprintf(", about %s left", remaining.Round(time.Second))
+	}
+
+	return progress
+}
+
+// startDeletionJob deletes the relevant posts of postList in the background,
+// showing the progress to the user in an ephemeral post updated periodically
+func (p *Plugin) startDeletionJob(postList *model.PostList, options *deletionOptions) {
+	job := &deletionJob{
+		ID:        model.NewId(),
+		UserID:    options.userID,
+		ChannelID: options.channelID,
+		Status:    jobStatusRunning,
+		StartAt:   model.GetMillis(),
+	}
+	p.saveJob(job)
+	if err := p.addJobToIndex(job); err != nil {
+		p.API.LogError("Unable to index job", "JobID", job.ID, "err", err)
+	}
+
+	beginningPost := p.sendEphemeralPost(options.userID, options.channelID, messageBeginning)
+
+	go p.runDeletionJob(job, postList, options, beginningPost)
+}
+
+func (p *Plugin) runDeletionJob(job *deletionJob, postList *model.PostList, options *deletionOptions, beginningPost *model.Post) {
+	stopHeartbeat := make(chan struct{})
+	defer close(stopHeartbeat)
+	go p.keepJobAlive(job.ID, stopHeartbeat)
+
+	// Only the deleted posts are kept in the daily usage of the user, whatever the outcome
+	defer func() { p.settleQuota(options, job.Deleted) }()
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.API.LogError("Deletion job crashed", "JobID", job.ID, "panic", fmt.Sprint(r))
+			p.finishJob(job, jobStatusFailed, "Because of a technical error, the housecleaning stopped.", beginningPost)
+		}
+	}()
+
+	if p.isChannelProtected(options.channelID) {
+		p.finishJob(job, jobStatusFailed, messageChannelProtected, beginningPost)
+		return
+	}
+
+	postListToDelete := getRelevantPostList(postList)
+
+	archiveLink := ""
+	if options.archiveFormat != "" {
+		var err error
+		archiveLink, err = p.archivePosts(postListToDelete, options)
+		if err != nil {
+			p.API.LogError("Unable to archive posts", "err", err)
+			p.finishJob(job, jobStatusFailed, "Unable to archive the posts, so none of them has been deleted.", beginningPost)
+			return
+		}
+	}
+
+	if p.isJobCancellationRequested(job.ID) {
+		p.finishJob(job, jobStatusCancelled, "The housecleaning has been cancelled before deleting any post.", beginningPost)
+		return
+	}
+
+	numCopied := 0
+	if options.moveChannelID != "" {
+		var err error
+		if numCopied, err = p.copyMovedPosts(postListToDelete, options); err != nil {
+			p.API.LogError("Unable to copy the moved posts", "err", err)
+			p.finishJob(job, jobStatusFailed, fmt.Sprintf(
+				"Because of a technical error, the posts could not be copied to ~%s, so none of the original posts has been deleted.",
+				p.getChannelName(options.moveChannelID),
+			), beginningPost)
+			return
+		}
+
+		// The posts have been copied, they must not be restored in this channel
+		options.optNoUndo = true
+	}
+
+	lastUpdate := time.Now()
+	lastCancelCheck := time.Now()
+	cancelled := false
+	result := p.deletePosts(postListToDelete, options, func(processed int, total int, result *deletePostResult) bool {
+		job.Processed = processed
+		job.Total = total
+		job.Deleted = result.numPostsDeleted
+
+		// Checked every second at most, not to read the KV store before each post
+		if processed < total && time.Since(lastCancelCheck) >= jobCancelCheckInterval {
+			lastCancelCheck = time.Now()
+			if p.isJobCancellationRequested(job.ID) {
+				cancelled = true
+				return false
+			}
+		}
+
+		if time.Since(lastUpdate) < jobProgressInterval {
+			return true
+		}
+		lastUpdate = time.Now()
+
+		p.saveJob(job)
+		beginningPost.Message = job.progressString()
+		p.API.UpdateEphemeralPost(job.UserID, beginningPost)
+		return true
+	})
+	job.Deleted = result.numPostsDeleted
+	p.audit(options, result, archiveLink)
+
+	report := result.String()
+	if options.moveChannelID != "" {
+		p.postMoveBreadcrumb(options, numCopied)
+		report = fmt.Sprintf(
+			"Successfully copied %d post%s to ~%s.\n%s",
+			numCopied, getPluralChar(numCopied), p.getChannelName(options.moveChannelID), report,
+		)
+	}
+
+	status := jobStatusDone
+	if cancelled {
+		status = jobStatusCancelled
+		report = fmt.Sprintf(
+			"The housecleaning has been cancelled after processing %d of %d posts.\n%s",
+			job.Processed, job.Total, report,
+		)
+
+		if options.moveChannelID != "" {
+			report += "\nThe posts not deleted yet are still in this channel, along with their copy."
+		}
+	}
+
+	if archiveLink != "" {
+		report += fmt.Sprintf("\nThe deleted posts have been [archived](%s).", archiveLink)
+	}
+
+	if len(result.deletedPosts) > 0 {
+		if err := p.saveUndoSnapshot(options, result.deletedPosts); err != nil {
+			p.API.LogError("Unable to save the snapshot of the deleted posts", "err", err)
+		} else {
+			report += "\n" + p.getUndoMessage()
+		}
+	}
+
+	p.finishJob(job, status, report, beginningPost)
+}
+
+// finishJob saves the final state of the job and reports it to the user
+func (p *Plugin) finishJob(job *deletionJob, status string, report string, beginningPost *model.Post) {
+	job.Status = status
+	job.Report = report
+	job.EndAt = model.GetMillis()
+	p.saveJob(job)
+
+	beginningPost.Message = report
+	p.API.UpdateEphemeralPost(job.UserID, beginningPost)
+}
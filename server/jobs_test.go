@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletionJobIsStale(t *testing.T) {
+	now := time.Now().UnixMilli()
+	old := now - jobStaleTimeout.Milliseconds() - 1
+	recent := now - jobHeartbeatInterval.Milliseconds()
+
+	for name, tc := range map[string]struct {
+		job      deletionJob
+		expected bool
+	}{
+		"running, recent heartbeat": {job: deletionJob{Status: jobStatusRunning, StartAt: old, HeartbeatAt: recent}, expected: false},
+		"running, old heartbeat":    {job: deletionJob{Status: jobStatusRunning, StartAt: old, HeartbeatAt: old}, expected: true},
+		"running, no heartbeat yet": {job: deletionJob{Status: jobStatusRunning, StartAt: recent}, expected: false},
+		"running, never any sign":   {job: deletionJob{Status: jobStatusRunning, StartAt: old}, expected: true},
+		"done, old heartbeat":       {job: deletionJob{Status: jobStatusDone, StartAt: old, HeartbeatAt: old}, expected: false},
+		"cancelled, old heartbeat":  {job: deletionJob{Status: jobStatusCancelled, StartAt: old, HeartbeatAt: old}, expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if stale := tc.job.isStale(now); stale != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, stale)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	// kvUndoPrefix prefixes the snapshots of the deleted posts, kept to be restored with /broom undo
+	kvUndoPrefix = "undo_"
+
+	// kvUndoChunkPrefix prefixes the chunks of posts of the undo snapshots
+	kvUndoChunkPrefix = "undochunk_"
+
+	// kvJobPrefix prefixes the state of the deletion jobs
+	kvJobPrefix = "job_"
+
+	// kvJobCancelPrefix prefixes the cancellation requests of the deletion jobs
+	kvJobCancelPrefix = "jobcancel_"
+
+	// kvJobIndexPrefix prefixes the lists of the recent jobs of a user in a channel
+	kvJobIndexPrefix = "jobindex_"
+
+	// kvRetentionPrefix prefixes the retention policies of the channels
+	kvRetentionPrefix = "retention_"
+
+	// kvTTLPrefix prefixes the TTL of the channels
+	kvTTLPrefix = "ttl_"
+
+	// kvTTLQueuePrefix prefixes the buckets of the expiry queue, one per minute
+	kvTTLQueuePrefix = "ttlqueue_"
+
+	// kvTTLQueueCursorKey stores the first minute of the expiry queue not processed yet
+	kvTTLQueueCursorKey = "ttlqueuecursor"
+
+	// kvProtectedPrefix prefixes the channels protected with /broom protect
+	kvProtectedPrefix = "protected_"
+
+	// kvProtectedAuthorsPrefix prefixes the authors protected or unprotected in a channel with /broom protect --user
+	kvProtectedAuthorsPrefix = "protectedauthors_"
+
+	// kvQuotaPrefix prefixes the number of posts deleted by a user during a day
+	kvQuotaPrefix = "quota_"
+
+	// kvApprovalPrefix prefixes the deletions waiting for an approval
+	kvApprovalPrefix = "approval_"
+
+	// kvAuditPrefix prefixes the records of the housecleanings
+	kvAuditPrefix = "audit_"
+
+	// kvHistoryKey stores the IDs of the most recent audit records
+	kvHistoryKey = "history"
+
+	// kvSchedulesKey stores all the scheduled housecleanings
+	kvSchedulesKey = "schedules"
+
+	// kvListPerPage is the number of keys fetched at once when listing the KV store
+	kvListPerPage = 1000
+)
+
+// listKVKeys returns all the keys of the plugin KV store starting with prefix
+func (p *Plugin) listKVKeys(prefix string) ([]string, error) {
+	keys := []string{}
+
+	for page := 0; ; page++ {
+		pageKeys, appErr := p.API.KVList(page, kvListPerPage)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		for _, key := range pageKeys {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		if len(pageKeys) < kvListPerPage {
+			return keys, nil
+		}
+	}
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"sync"
+	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 
 	"github.com/pkg/errors"
 )
@@ -23,8 +25,14 @@ type Plugin struct {
 	configuration *configuration
 
 	botUserID string
+
+	// backgroundJobs are the jobs scheduled on activation, closed on deactivation
+	backgroundJobs []*cluster.Job
 }
 
+// undoExpiryInterval is the interval between two deletions of the expired undo snapshots
+const undoExpiryInterval = 5 * time.Minute
+
 // OnActivate is invoked when the plugin is activated. If an error is returned, the plugin will be deactivated.
 func (p *Plugin) OnActivate() error {
 	if p.API.GetConfig().ServiceSettings.SiteURL == nil {
@@ -49,6 +57,49 @@ func (p *Plugin) OnActivate() error {
 
 	p.botUserID = botUserID
 
+	if err := p.scheduleBackgroundJob("undo_expiry", undoExpiryInterval, p.expireUndoSnapshots); err != nil {
+		return err
+	}
+
+	if err := p.scheduleBackgroundJob("schedules", schedulesInterval, p.runSchedules); err != nil {
+		return err
+	}
+
+	if err := p.scheduleBackgroundJob("retention", retentionSweepInterval, p.sweepRetentionPolicies); err != nil {
+		return err
+	}
+
+	if err := p.scheduleBackgroundJob("ttl_expiry", ttlExpiryInterval, p.deleteExpiredPosts); err != nil {
+		return err
+	}
+
+	if err := p.scheduleBackgroundJob("approval_expiry", approvalExpiryInterval, p.expireApprovalRequests); err != nil {
+		return err
+	}
+
 	// Registering command in OnConfigurationChange()
 	return nil
 }
+
+// OnDeactivate is invoked when the plugin is deactivated.
+func (p *Plugin) OnDeactivate() error {
+	for _, job := range p.backgroundJobs {
+		if err := job.Close(); err != nil {
+			p.API.LogError("Failed to close background job", "err", err)
+		}
+	}
+	p.backgroundJobs = nil
+
+	return nil
+}
+
+// scheduleBackgroundJob runs callback every interval, on a single server of the cluster at a time
+func (p *Plugin) scheduleBackgroundJob(key string, interval time.Duration, callback func()) error {
+	job, err := cluster.Schedule(p.API, key, cluster.MakeWaitForRoundedInterval(interval), callback)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to schedule background job %s", key)
+	}
+
+	p.backgroundJobs = append(p.backgroundJobs, job)
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// messageChannelProtected is sent when trying to delete posts in a protected channel
+const messageChannelProtected = "This channel is protected, its posts can't be broomed."
+
+// protectedChannel is a channel protected with /broom protect
+type protectedChannel struct {
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id"`
+	CreateAt  int64  `json:"create_at"`
+}
+
+func getProtectedChannelKey(channelID string) string {
+	return kvProtectedPrefix + channelID
+}
+
+// splitChannelIDs splits a comma-separated list of channel IDs from the configuration
+func splitChannelIDs(channelIDs string) []string {
+	result := []string{}
+	for _, channelID := range strings.Split(channelIDs, ",") {
+		if channelID = strings.TrimSpace(channelID); channelID != "" {
+			result = append(result, channelID)
+		}
+	}
+
+	return result
+}
+
+func (p *Plugin) protectChannel(channelID string, userID string) error {
+	_, err := p.client.KV.Set(getProtectedChannelKey(channelID), &protectedChannel{
+		ChannelID: channelID,
+		UserID:    userID,
+		CreateAt:  model.GetMillis(),
+	})
+	return err
+}
+
+func (p *Plugin) unprotectChannel(channelID string) error {
+	return p.client.KV.Delete(getProtectedChannelKey(channelID))
+}
+
+// isChannelProtectedBySettings tells if the plugin settings forbid to delete posts in the channel
+func (p *Plugin) isChannelProtectedBySettings(channelID string) bool {
+	conf := p.getConfiguration()
+
+	if contains(splitChannelIDs(conf.ProtectedChannelIDs), channelID) {
+		return true
+	}
+
+	allowedChannelIDs := splitChannelIDs(conf.AllowedChannelIDs)
+	return len(allowedChannelIDs) > 0 && !contains(allowedChannelIDs, channelID)
+}
+
+// isChannelProtected tells if posts must not be deleted in the channel,
+// because of the plugin settings or because it has been protected with /broom protect.
+// When in doubt, the channel is considered protected
+func (p *Plugin) isChannelProtected(channelID string) bool {
+	protected, err := p.checkChannelProtected(channelID)
+	if err != nil {
+		p.API.LogError("Unable to check if the channel is protected", "ChannelID", channelID, "err", err)
+		return true
+	}
+
+	return protected
+}
+
+// checkChannelProtected is like isChannelProtected, but returns the error instead of protecting the channel
+func (p *Plugin) checkChannelProtected(channelID string) (bool, error) {
+	if p.isChannelProtectedBySettings(channelID) {
+		return true, nil
+	}
+
+	var protected *protectedChannel
+	if err := p.client.KV.Get(getProtectedChannelKey(channelID), &protected); err != nil {
+		return false, err
+	}
+
+	return protected != nil, nil
+}
+
+// channelAuthorOverrides adds or removes protected authors in a channel, over the plugin settings
+type channelAuthorOverrides struct {
+	ChannelID          string   `json:"channel_id"`
+	ProtectedUserIDs   []string `json:"protected_user_ids"`
+	UnprotectedUserIDs []string `json:"unprotected_user_ids"`
+}
+
+func getProtectedAuthorsKey(channelID string) string {
+	return kvProtectedAuthorsPrefix + channelID
+}
+
+// updateAuthorOverrides atomically applies update to the protected authors of the channel
+func (p *Plugin) updateAuthorOverrides(channelID string, update func(overrides *channelAuthorOverrides)) error {
+	return p.client.KV.SetAtomicWithRetries(getProtectedAuthorsKey(channelID), func(oldValue []byte) (any, error) {
+		overrides := &channelAuthorOverrides{ChannelID: channelID}
+		if len(oldValue) > 0 {
+			if err := json.Unmarshal(oldValue, overrides); err != nil {
+				return nil, err
+			}
+		}
+
+		update(overrides)
+		return overrides, nil
+	})
+}
+
+// protectAuthors protects the posts of the users in the channel
+func (p *Plugin) protectAuthors(channelID string, userIDs []string) error {
+	return p.updateAuthorOverrides(channelID, func(overrides *channelAuthorOverrides) {
+		for _, userID := range userIDs {
+			overrides.UnprotectedUserIDs = removeValue(overrides.UnprotectedUserIDs, userID)
+			if !contains(overrides.ProtectedUserIDs, userID) {
+				overrides.ProtectedUserIDs = append(overrides.ProtectedUserIDs, userID)
+			}
+		}
+	})
+}
+
+// unprotectAuthors stops protecting the posts of the users in the channel,
+// even if they are protected by the plugin settings
+func (p *Plugin) unprotectAuthors(channelID string, userIDs []string) error {
+	return p.updateAuthorOverrides(channelID, func(overrides *channelAuthorOverrides) {
+		for _, userID := range userIDs {
+			overrides.ProtectedUserIDs = removeValue(overrides.ProtectedUserIDs, userID)
+			if !contains(overrides.UnprotectedUserIDs, userID) {
+				overrides.UnprotectedUserIDs = append(overrides.UnprotectedUserIDs, userID)
+			}
+		}
+	})
+}
+
+// getProtectedAuthorIDsBySettings returns the IDs of the users whose posts are protected by the plugin settings
+func (p *Plugin) getProtectedAuthorIDsBySettings() ([]string, error) {
+	usernames := []string{}
+	for _, username := range strings.Split(p.getConfiguration().ProtectedAuthors, ",") {
+		if username = strings.TrimPrefix(strings.TrimSpace(username), "@"); username != "" {
+			usernames = append(usernames, username)
+		}
+	}
+
+	if len(usernames) == 0 {
+		return []string{}, nil
+	}
+
+	users, appErr := p.API.GetUsersByUsernames(usernames)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	userIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.Id)
+	}
+
+	return userIDs, nil
+}
+
+// getAuthorProtection returns a function telling if the posts of a user must not be deleted in the channel,
+// because of the plugin settings or because they have been protected with /broom protect --user.
+// When in doubt, every author is considered protected
+func (p *Plugin) getAuthorProtection(channelID string) func(userID string) bool {
+	isProtected, err := p.loadAuthorProtection(channelID)
+	if err != nil {
+		p.API.LogError("Unable to get the protected authors", "ChannelID", channelID, "err", err)
+		return func(string) bool { return true }
+	}
+
+	return isProtected
+}
+
+// loadAuthorProtection is like getAuthorProtection, but returns the error instead of protecting every author
+func (p *Plugin) loadAuthorProtection(channelID string) (func(userID string) bool, error) {
+	settingsUserIDs, err := p.getProtectedAuthorIDsBySettings()
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides *channelAuthorOverrides
+	if err := p.client.KV.Get(getProtectedAuthorsKey(channelID), &overrides); err != nil {
+		return nil, err
+	}
+
+	return func(userID string) bool {
+		return isAuthorProtected(userID, settingsUserIDs, overrides)
+	}, nil
+}
+
+// isAuthorProtected tells if the posts of the user are protected by the settings or by the overrides of the channel, if any
+func isAuthorProtected(userID string, settingsUserIDs []string, overrides *channelAuthorOverrides) bool {
+	if overrides != nil {
+		if contains(overrides.ProtectedUserIDs, userID) {
+			return true
+		}
+		if contains(overrides.UnprotectedUserIDs, userID) {
+			return false
+		}
+	}
+
+	return contains(settingsUserIDs, userID)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsChannelProtected(t *testing.T) {
+	protected, err := json.Marshal(&protectedChannel{ChannelID: "channel", UserID: "admin"})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		protectedChannelIDs string
+		allowedChannelIDs   string
+		kvValue             []byte
+		kvError             *model.AppError
+		expected            bool
+	}{
+		"not protected": {
+			expected: false,
+		},
+		"protected by the settings": {
+			protectedChannelIDs: "other, channel",
+			expected:            true,
+		},
+		"allowed by the settings": {
+			allowedChannelIDs: "other, channel",
+			expected:          false,
+		},
+		"not allowed by the settings": {
+			allowedChannelIDs: "other",
+			expected:          true,
+		},
+		"protected with the command": {
+			kvValue:  protected,
+			expected: true,
+		},
+		"allowed by the settings but protected with the command": {
+			allowedChannelIDs: "channel",
+			kvValue:           protected,
+			expected:          true,
+		},
+		"protection unknown": {
+			kvError:  model.NewAppError("KVGet", "error", nil, "", http.StatusInternalServerError),
+			expected: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("KVGet", getProtectedChannelKey("channel")).Return(tc.kvValue, tc.kvError).Maybe()
+			api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+			p.setConfiguration(&configuration{
+				ProtectedChannelIDs: tc.protectedChannelIDs,
+				AllowedChannelIDs:   tc.allowedChannelIDs,
+			})
+
+			assert.Equal(t, tc.expected, p.isChannelProtected("channel"))
+
+			_, err := p.checkChannelProtected("channel")
+			assert.Equal(t, tc.kvError != nil, err != nil)
+		})
+	}
+}
+
+func TestGetAuthorProtection(t *testing.T) {
+	overrides, err := json.Marshal(&channelAuthorOverrides{
+		ChannelID:          "channel",
+		ProtectedUserIDs:   []string{"user"},
+		UnprotectedUserIDs: []string{"bot"},
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		kvValue   []byte
+		kvError   *model.AppError
+		expected  map[string]bool
+		expectErr bool
+	}{
+		"protected by the settings": {
+			expected: map[string]bool{"bot": true, "user": false, "other": false},
+		},
+		"overridden in the channel": {
+			kvValue:  overrides,
+			expected: map[string]bool{"bot": false, "user": true, "other": false},
+		},
+		"protection unknown": {
+			kvError:   model.NewAppError("KVGet", "error", nil, "", http.StatusInternalServerError),
+			expected:  map[string]bool{"bot": true, "user": true, "other": true},
+			expectErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("GetUsersByUsernames", []string{"bot"}).Return([]*model.User{{Id: "bot", Username: "bot"}}, nil)
+			api.On("KVGet", getProtectedAuthorsKey("channel")).Return(tc.kvValue, tc.kvError)
+			api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+			p.setConfiguration(&configuration{ProtectedAuthors: "@bot"})
+
+			isProtected := p.getAuthorProtection("channel")
+			for userID, expected := range tc.expected {
+				assert.Equal(t, expected, isProtected(userID), userID)
+			}
+
+			_, err := p.loadAuthorProtection("channel")
+			assert.Equal(t, tc.expectErr, err != nil)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// dailyUsage is the number of posts deleted by a user during a day, in UTC.
+// A single value is kept per user, and it starts again from zero every day
+type dailyUsage struct {
+	Day   string `json:"day"`
+	Posts int    `json:"posts"`
+}
+
+// quotaReservation is the number of posts counted in the daily usage of a user before deleting them,
+// to be corrected once the deletion is over
+type quotaReservation struct {
+	userID   string
+	day      string
+	numPosts int
+}
+
+func getQuotaKey(userID string) string {
+	return kvQuotaPrefix + userID
+}
+
+// getQuotaDay returns the day the daily usage is counted for
+func getQuotaDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// getDailyUsage returns the number of posts deleted by the user today
+func (p *Plugin) getDailyUsage(userID string) (int, error) {
+	var usage *dailyUsage
+	if err := p.client.KV.Get(getQuotaKey(userID), &usage); err != nil {
+		return 0, err
+	}
+
+	if usage == nil || usage.Day != getQuotaDay(time.Now()) {
+		return 0, nil
+	}
+
+	return usage.Posts, nil
+}
+
+// updateDailyUsage atomically replaces the number of posts deleted by the user on the day by the result of update.
+// The usage of a past day can't be updated anymore. update may return an error to leave the usage unchanged
+func (p *Plugin) updateDailyUsage(userID string, day string, update func(usage int) (int, error)) error {
+	return p.client.KV.SetAtomicWithRetries(getQuotaKey(userID), func(oldValue []byte) (any, error) {
+		usage := &dailyUsage{Day: getQuotaDay(time.Now())}
+		if len(oldValue) > 0 {
+			var oldUsage *dailyUsage
+			if err := json.Unmarshal(oldValue, &oldUsage); err != nil {
+				return nil, err
+			}
+			if oldUsage != nil && oldUsage.Day == usage.Day {
+				usage = oldUsage
+			}
+		}
+
+		if usage.Day != day {
+			return usage, nil
+		}
+
+		posts, err := update(usage.Posts)
+		if err != nil {
+			return nil, err
+		}
+
+		usage.Posts = max(posts, 0)
+		return usage, nil
+	})
+}
+
+// countPostsToDelete returns the number of posts deleted along with the selected posts,
+// including the replies deleted with the thread of their root post
+func countPostsToDelete(selected *model.PostList) int {
+	keptRoots := getKeptRoots(selected)
+
+	numPosts := 0
+	for _, postID := range selected.Order {
+		post := selected.Posts[postID]
+
+		if post.RootId == "" {
+			if !keptRoots[post.Id] {
+				numPosts += 1 + int(post.ReplyCount)
+			}
+		} else if _, ok := selected.Posts[post.RootId]; !ok || keptRoots[post.RootId] {
+			numPosts++
+		}
+	}
+
+	return numPosts
+}
+
+// getNumPostsToDelete returns the number of posts deleted along with the posts of postList matching the criteria of options
+func (p *Plugin) getNumPostsToDelete(postList *model.PostList, options *deletionOptions) int {
+	selected := p.selectPostsToDelete(postList, options, new(deletePostResult))
+	p.fillMissingReplyCounts(selected)
+	return countPostsToDelete(selected)
+}
+
+// checkQuota returns a userError if the user is not permitted to delete numPosts posts at once,
+// or if it would exceed their daily quota. System admins are exempt
+func (p *Plugin) checkQuota(userID string, numPosts int) userError {
+	conf := p.getConfiguration()
+	if conf.MaxPostsPerCommand <= 0 && conf.MaxPostsPerUserPerDay <= 0 {
+		return nil
+	}
+
+	if isSysadmin(p, userID) {
+		return nil
+	}
+
+	if conf.MaxPostsPerCommand > 0 && numPosts > conf.MaxPostsPerCommand {
+		return errors.Errorf(
+			"Sorry, you can't delete more than %d post%s at once, and this would delete %d posts.",
+			conf.MaxPostsPerCommand, getPluralChar(conf.MaxPostsPerCommand), numPosts,
+		)
+	}
+
+	if conf.MaxPostsPerUserPerDay <= 0 {
+		return nil
+	}
+
+	usage, err := p.getDailyUsage(userID)
+	if err != nil {
+		p.API.LogError("Unable to get daily usage", "UserID", userID, "err", err)
+		return errors.New("Error when checking your daily quota")
+	}
+
+	if remaining := max(conf.MaxPostsPerUserPerDay-usage, 0); numPosts > remaining {
+		return p.getDailyQuotaError(remaining, numPosts)
+	}
+
+	return nil
+}
+
+// getDailyQuotaError tells the user that deleting numPosts posts would exceed their daily quota
+func (p *Plugin) getDailyQuotaError(remaining int, numPosts int) userError {
+	maxPosts := p.getConfiguration().MaxPostsPerUserPerDay
+	return errors.Errorf(
+		"Sorry, you can delete up to %d post%s per day, and you have %d left today (UTC), but this would delete %d posts.",
+		maxPosts, getPluralChar(maxPosts), remaining, numPosts,
+	)
+}
+
+// checkQuotaForPosts checks the quota of the user for the posts of postList matching the criteria of options
+func (p *Plugin) checkQuotaForPosts(postList *model.PostList, options *deletionOptions) userError {
+	return p.checkQuota(options.userID, p.getNumPostsToDelete(postList, options))
+}
+
+// reserveQuotaForPosts checks the quota of the user for the posts of postList matching the criteria of options,
+// and counts them right away in their daily usage, so that simultaneous deletions can't exceed it together.
+// The reservation, nil if the user has no daily quota, is kept in options until settleQuota is called
+func (p *Plugin) reserveQuotaForPosts(postList *model.PostList, options *deletionOptions) userError {
+	numPosts := p.getNumPostsToDelete(postList, options)
+
+	if userErr := p.checkQuota(options.userID, numPosts); userErr != nil {
+		return userErr
+	}
+
+	maxPosts := p.getConfiguration().MaxPostsPerUserPerDay
+	if maxPosts <= 0 || numPosts == 0 || isSysadmin(p, options.userID) {
+		return nil
+	}
+
+	reservation := &quotaReservation{
+		userID:   options.userID,
+		day:      getQuotaDay(time.Now()),
+		numPosts: numPosts,
+	}
+
+	remaining := -1
+	if err := p.updateDailyUsage(reservation.userID, reservation.day, func(usage int) (int, error) {
+		if usage+numPosts > maxPosts {
+			remaining = max(maxPosts-usage, 0)
+			return 0, errors.New("daily quota exceeded")
+		}
+		return usage + numPosts, nil
+	}); err != nil {
+		if remaining >= 0 {
+			return p.getDailyQuotaError(remaining, numPosts)
+		}
+
+		p.API.LogError("Unable to update daily usage", "UserID", options.userID, "err", err)
+		return errors.New("Error when checking your daily quota")
+	}
+
+	options.quotaReservation = reservation
+	return nil
+}
+
+// settleQuota replaces the reserved posts by the number of posts actually deleted in the daily usage of the user
+func (p *Plugin) settleQuota(options *deletionOptions, numDeleted int) {
+	reservation := options.quotaReservation
+	options.quotaReservation = nil
+	if reservation == nil || reservation.numPosts == numDeleted {
+		return
+	}
+
+	if err := p.updateDailyUsage(reservation.userID, reservation.day, func(usage int) (int, error) {
+		return usage - reservation.numPosts + numDeleted, nil
+	}); err != nil {
+		p.API.LogError("Unable to update daily usage", "UserID", reservation.userID, "err", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountPostsToDelete(t *testing.T) {
+	for name, tc := range map[string]struct {
+		posts    []*model.Post
+		expected int
+	}{
+		"root posts without replies": {
+			posts:    []*model.Post{{Id: "root1"}, {Id: "root2"}},
+			expected: 2,
+		},
+		"root post with its thread": {
+			posts:    []*model.Post{{Id: "reply2", RootId: "root"}, {Id: "reply1", RootId: "root"}, {Id: "root", ReplyCount: 2}},
+			expected: 3,
+		},
+		"root post kept for the replies not selected": {
+			posts:    []*model.Post{{Id: "reply", RootId: "root"}, {Id: "root", ReplyCount: 2}},
+			expected: 1,
+		},
+		"reply without its root": {
+			posts:    []*model.Post{{Id: "reply", RootId: "root"}},
+			expected: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			selected := model.NewPostList()
+			for _, post := range tc.posts {
+				selected.AddPost(post)
+				selected.AddOrder(post.Id)
+			}
+
+			assert.Equal(t, tc.expected, countPostsToDelete(selected))
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// retentionSweepInterval is the interval between two deletions of the posts older than the retention policies
+const retentionSweepInterval = time.Hour
+
+// retentionPolicy makes Broomer delete the posts of a channel older than a number of days
+type retentionPolicy struct {
+	ChannelID   string `json:"channel_id"`
+	CreatorID   string `json:"creator_id"`
+	Days        int    `json:"days"`
+	CreateAt    int64  `json:"create_at"`
+	LastSweepAt int64  `json:"last_sweep_at"`
+
+	// SweptUntil is the date before which all the posts have already been handled by the previous sweeps
+	SweptUntil int64 `json:"swept_until"`
+}
+
+func getRetentionPolicyKey(channelID string) string {
+	return kvRetentionPrefix + channelID
+}
+
+func (p *Plugin) saveRetentionPolicy(policy *retentionPolicy) error {
+	_, err := p.client.KV.Set(getRetentionPolicyKey(policy.ChannelID), policy)
+	return err
+}
+
+// getRetentionPolicy returns the retention policy of the channel, or nil if there is none
+func (p *Plugin) getRetentionPolicy(channelID string) (*retentionPolicy, error) {
+	var policy *retentionPolicy
+	if err := p.client.KV.Get(getRetentionPolicyKey(channelID), &policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func (p *Plugin) deleteRetentionPolicy(channelID string) error {
+	return p.client.KV.Delete(getRetentionPolicyKey(channelID))
+}
+
+// sweepRetentionPolicies deletes the posts older than the retention policy of each channel.
+// It is run as a background job, on a single server of the cluster at a time
+func (p *Plugin) sweepRetentionPolicies() {
+	keys, err := p.listKVKeys(kvRetentionPrefix)
+	if err != nil {
+		p.API.LogError("Unable to list retention policies", "err", err)
+		return
+	}
+
+	for _, key := range keys {
+		var policy *retentionPolicy
+		if err := p.client.KV.Get(key, &policy); err != nil || policy == nil {
+			continue
+		}
+
+		sweptUntil := p.sweepChannel(policy)
+		if err := p.markRetentionPolicySwept(policy, sweptUntil); err != nil {
+			p.API.LogError("Unable to save retention policy", "ChannelID", policy.ChannelID, "err", err)
+		}
+	}
+}
+
+// markRetentionPolicySwept saves the progress of the sweep of the policy. The policy may have been removed
+// or replaced during the sweep, in which case it is left as is
+func (p *Plugin) markRetentionPolicySwept(policy *retentionPolicy, sweptUntil int64) error {
+	changed := false
+	err := p.client.KV.SetAtomicWithRetries(getRetentionPolicyKey(policy.ChannelID), func(oldValue []byte) (any, error) {
+		var current *retentionPolicy
+		if len(oldValue) > 0 {
+			if err := json.Unmarshal(oldValue, &current); err != nil {
+				return nil, err
+			}
+		}
+
+		if current == nil || current.Days != policy.Days || current.CreateAt != policy.CreateAt {
+			changed = true
+			return nil, errors.New("retention policy changed during the sweep")
+		}
+
+		current.LastSweepAt = model.GetMillis()
+		current.SweptUntil = sweptUntil
+		return current, nil
+	})
+	if changed {
+		return nil
+	}
+
+	return err
+}
+
+// sweepChannel deletes the posts of the channel older than its retention policy, except the pinned ones
+// and the threads having recent replies.
+// Returns the date before which the next sweep doesn't have to read the channel history
+func (p *Plugin) sweepChannel(policy *retentionPolicy) int64 {
+	channel, appErr := p.API.GetChannel(policy.ChannelID)
+	if appErr != nil {
+		p.API.LogError("Unable to get channel", "ChannelID", policy.ChannelID, "appErr", appErr)
+		return policy.SweptUntil
+	}
+
+	// The policy applies as long as the user who set it up is allowed to broom and to delete the posts
+	if !isAllowedToBroom(p, policy.CreatorID, channel.TeamId, channel.Id) {
+		p.API.LogWarn("Retention policy not applied, its creator is not allowed to use /broom anymore",
+			"ChannelID", policy.ChannelID, "UserID", policy.CreatorID)
+		return policy.SweptUntil
+	}
+
+	if !canDeleteOthersPosts(p, policy.CreatorID, policy.ChannelID) {
+		p.API.LogWarn("Retention policy not applied, its creator is not permitted to delete posts anymore",
+			"ChannelID", policy.ChannelID, "UserID", policy.CreatorID)
+		return policy.SweptUntil
+	}
+
+	if p.isChannelProtected(policy.ChannelID) {
+		return policy.SweptUntil
+	}
+
+	threshold := time.Now().Add(-time.Duration(policy.Days) * 24 * time.Hour).UnixMilli()
+	postList, sweptUntil, appErr := p.getExpiredPosts(policy.ChannelID, threshold, policy.SweptUntil)
+	if appErr != nil {
+		p.API.LogError("Unable to retrieve posts", "ChannelID", policy.ChannelID, "appErr", appErr)
+		return policy.SweptUntil
+	}
+
+	if len(postList.Order) == 0 {
+		return sweptUntil
+	}
+
+	options := &deletionOptions{
+		channelID:             policy.ChannelID,
+		userID:                policy.CreatorID,
+		command:               fmt.Sprintf("/broom %s %s %d", retentionTrigger, retentionSetTrigger, policy.Days),
+		untilTime:             threshold - 1,
+		optDeletePinnedPosts:  false,
+		optNoUndo:             true,
+		permDeleteOthersPosts: true,
+	}
+	result := p.deletePosts(postList, options, nil)
+	if result.numPostsDeleted > 0 {
+		p.audit(options, result, "")
+	}
+
+	p.API.LogInfo("Retention policy applied",
+		"ChannelID", policy.ChannelID,
+		"Deleted", result.numPostsDeleted,
+		"Pinned", result.pinnedPostErrors,
+		"Protected", result.protectedPostErrors,
+		"Errors", result.technicalErrors,
+	)
+
+	// The posts that could not be deleted are retried by the next sweep,
+	// and so are the root posts kept until the replies of their thread expire
+	for _, post := range append(result.failedPosts, result.keptRootPosts...) {
+		sweptUntil = min(sweptUntil, post.CreateAt)
+	}
+
+	return sweptUntil
+}
+
+// getExpiredPosts returns the posts of the channel created before threshold. The posts older than sweptUntil
+// have been handled by the previous sweeps, so the channel history is only read until them.
+// Deleting a root post deletes its whole thread, so the roots having replies created after threshold are kept,
+// and only their old replies are returned.
+// Returns the posts, and the date before which the next sweep doesn't have to read the channel history
+func (p *Plugin) getExpiredPosts(channelID string, threshold int64, sweptUntil int64) (*model.PostList, int64, *model.AppError) {
+	expired := model.NewPostList()
+	rootsWithRecentReplies := map[string]bool{}
+	nextSweptUntil := threshold
+
+	// The posts are walked from the most recent one, so the recent replies are known before the old roots
+	appErr := p.walkChannelPosts(channelID, func(post *model.Post) bool {
+		if post.CreateAt < sweptUntil {
+			return false
+		}
+
+		if post.CreateAt >= threshold {
+			if post.RootId != "" {
+				rootsWithRecentReplies[post.RootId] = true
+			}
+			return true
+		}
+
+		if post.RootId == "" && rootsWithRecentReplies[post.Id] {
+			// Checked again by the next sweeps, until all the replies are old enough
+			nextSweptUntil = min(nextSweptUntil, post.CreateAt)
+			return true
+		}
+
+		expired.AddPost(post)
+		expired.AddOrder(post.Id)
+		return true
+	})
+	if appErr != nil {
+		return nil, 0, appErr
+	}
+
+	return expired, nextSweptUntil, nil
+}
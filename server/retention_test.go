@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetExpiredPosts(t *testing.T) {
+	const threshold = 1000
+
+	// From the most recent post, like the channel history
+	posts := []*model.Post{
+		{Id: "recentReply", RootId: "oldRootWithRecentReply", CreateAt: 1500},
+		{Id: "recentRoot", CreateAt: 1200},
+		{Id: "oldReplyOfKeptRoot", RootId: "oldRootWithRecentReply", CreateAt: 900},
+		{Id: "oldReply", RootId: "oldRoot", CreateAt: 800},
+		{Id: "oldRootWithRecentReply", CreateAt: 700},
+		{Id: "oldRoot", CreateAt: 600},
+		{Id: "alreadySwept", CreateAt: 100},
+	}
+
+	page := model.NewPostList()
+	for _, post := range posts {
+		page.AddPost(post)
+		page.AddOrder(post.Id)
+	}
+
+	api := &plugintest.API{}
+	api.On("GetPostsForChannel", "channel", 0, postsPerPage).Return(page, nil)
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	expired, sweptUntil, appErr := p.getExpiredPosts("channel", threshold, 500)
+	require.Nil(t, appErr)
+
+	assert.Equal(t, []string{"oldReplyOfKeptRoot", "oldReply", "oldRoot"}, expired.Order)
+	assert.Equal(t, int64(700), sweptUntil, "the kept root should be checked again by the next sweep")
+
+	t.Run("no recent post", func(t *testing.T) {
+		expired, sweptUntil, appErr := p.getExpiredPosts("channel", 2000, 0)
+		require.Nil(t, appErr)
+		assert.Len(t, expired.Order, len(posts))
+		assert.Equal(t, int64(2000), sweptUntil)
+	})
+}
+
+func TestMarkRetentionPolicySwept(t *testing.T) {
+	policy := &retentionPolicy{ChannelID: "channel", Days: 30, CreateAt: 100}
+
+	for name, tc := range map[string]struct {
+		current       *retentionPolicy
+		expectedSaved bool
+	}{
+		"unchanged policy": {
+			current:       &retentionPolicy{ChannelID: "channel", Days: 30, CreateAt: 100},
+			expectedSaved: true,
+		},
+		"removed during the sweep": {
+			current: nil,
+		},
+		"set again during the sweep": {
+			current: &retentionPolicy{ChannelID: "channel", Days: 7, CreateAt: 200},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var data []byte
+			if tc.current != nil {
+				var err error
+				data, err = json.Marshal(tc.current)
+				require.NoError(t, err)
+			}
+
+			api := &plugintest.API{}
+			api.On("KVGet", getRetentionPolicyKey("channel")).Return(data, nil)
+			if tc.expectedSaved {
+				api.On("KVSetWithOptions", getRetentionPolicyKey("channel"), mock.MatchedBy(func(value []byte) bool {
+					var saved *retentionPolicy
+					return json.Unmarshal(value, &saved) == nil && saved.SweptUntil == 1000 && saved.LastSweepAt > 0
+				}), mock.Anything).Return(true, nil).Once()
+			}
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+
+			require.NoError(t, p.markRetentionPolicySwept(policy, 1000))
+			if !tc.expectedSaved {
+				api.AssertNotCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// schedulesInterval is the interval between two checks of the schedules to run
+const schedulesInterval = time.Minute
+
+// channelSchedule is a housecleaning run periodically in a channel, on behalf of its creator
+type channelSchedule struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	TeamID    string `json:"team_id"`
+	CreatorID string `json:"creator_id"`
+	CronExpr  string `json:"cron_expr"`
+	Timezone  string `json:"timezone"`
+	Selector  string `json:"selector"`
+	CreateAt  int64  `json:"create_at"`
+	LastRunAt int64  `json:"last_run_at"`
+}
+
+func (p *Plugin) getSchedules() ([]*channelSchedule, error) {
+	var schedules []*channelSchedule
+	if err := p.client.KV.Get(kvSchedulesKey, &schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// updateSchedules atomically replaces the schedules with the ones returned by update
+func (p *Plugin) updateSchedules(update func(schedules []*channelSchedule) ([]*channelSchedule, error)) error {
+	return p.client.KV.SetAtomicWithRetries(kvSchedulesKey, func(oldValue []byte) (any, error) {
+		var schedules []*channelSchedule
+		if len(oldValue) > 0 {
+			if err := json.Unmarshal(oldValue, &schedules); err != nil {
+				return nil, err
+			}
+		}
+
+		return update(schedules)
+	})
+}
+
+// runSchedules runs the schedules due at the current minute.
+// It is run every minute as a background job, on a single server of the cluster at a time
+func (p *Plugin) runSchedules() {
+	schedules, err := p.getSchedules()
+	if err != nil {
+		p.API.LogError("Unable to get schedules", "err", err)
+		return
+	}
+
+	now := time.Now().Truncate(time.Minute)
+	for _, schedule := range schedules {
+		if schedule.LastRunAt >= now.UnixMilli() {
+			continue // already run at this minute
+		}
+
+		cron, err := parseCron(schedule.CronExpr)
+		if err != nil {
+			p.API.LogError("Invalid schedule", "ScheduleID", schedule.ID, "err", err)
+			continue
+		}
+
+		location, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			location = time.UTC
+		}
+
+		if !cron.matches(now.In(location)) {
+			continue
+		}
+
+		if err := p.markScheduleRun(schedule.ID, now); err != nil {
+			p.API.LogError("Unable to save schedule run", "ScheduleID", schedule.ID, "err", err)
+			continue
+		}
+
+		p.runSchedule(schedule)
+	}
+}
+
+func (p *Plugin) markScheduleRun(scheduleID string, runAt time.Time) error {
+	return p.updateSchedules(func(schedules []*channelSchedule) ([]*channelSchedule, error) {
+		for _, schedule := range schedules {
+			if schedule.ID == scheduleID {
+				schedule.LastRunAt = runAt.UnixMilli()
+			}
+		}
+
+		return schedules, nil
+	})
+}
+
+// runSchedule runs the housecleaning of the schedule and posts a summary in the channel
+func (p *Plugin) runSchedule(schedule *channelSchedule) {
+	report, err := p.executeScheduledCommand(schedule)
+	if err != nil {
+		report = err.Error()
+	}
+
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: schedule.ChannelID,
+		Message: fmt.Sprintf(
+			"Scheduled housecleaning `/broom %s` set up by %s:\n%s",
+			schedule.Selector, p.getUserMention(schedule.CreatorID), report,
+		),
+	}); appErr != nil {
+		p.API.LogError("Unable to post schedule summary", "ScheduleID", schedule.ID, "appErr", appErr)
+	}
+}
+
+// executeScheduledCommand runs the command of the schedule on behalf of its creator.
+// Returns the report of the housecleaning, or a user-friendly error
+func (p *Plugin) executeScheduledCommand(schedule *channelSchedule) (string, error) {
+	subcommand, options, userErr := p.parseAndCheckCommandArgs(&model.CommandArgs{
+		Command:   "/broom " + schedule.Selector,
+		UserId:    schedule.CreatorID,
+		ChannelId: schedule.ChannelID,
+		TeamId:    schedule.TeamID,
+	})
+	if userErr != nil {
+		return "", userErr
+	}
+
+	if !isAllowedToBroom(p, options.userID, schedule.TeamID, options.channelID) {
+		return "", errors.Errorf("%s is not allowed to use /broom here anymore", p.getUserMention(options.userID))
+	}
+
+	if p.isChannelProtected(options.channelID) {
+		return "", errors.New(messageChannelProtected)
+	}
+
+	if !canDeletePost(p, options.userID, options.channelID) {
+		return "", errors.Errorf("%s is not permitted to delete posts anymore", p.getUserMention(options.userID))
+	}
+
+	postList, err := p.getScheduledPosts(subcommand, options)
+	if err != nil {
+		p.API.LogError("Unable to retrieve posts", "err", err)
+		return "", errors.New("Error when retrieving the posts to delete")
+	}
+
+	postListToDelete := getRelevantPostList(postList)
+	if options.optDryRun {
+		return p.getDryRunReport(postListToDelete, options), nil
+	}
+
+	// Like any large housecleaning, it waits for the approval of a second user
+	if p.requiresApproval(postListToDelete, options) {
+		if userErr := p.checkQuotaForPosts(postListToDelete, options); userErr != nil {
+			return "", userErr
+		}
+
+		if !p.requestApproval(postListToDelete, options) {
+			return "", errors.New("Error when requesting the approval of the housecleaning")
+		}
+
+		return fmt.Sprintf(
+			"This housecleaning has to be approved by someone else in ~%s first.",
+			p.getChannelName(p.getConfiguration().ApproversChannelID),
+		), nil
+	}
+
+	if userErr := p.reserveQuotaForPosts(postListToDelete, options); userErr != nil {
+		return "", userErr
+	}
+
+	archiveLink := ""
+	if options.archiveFormat != "" {
+		if archiveLink, err = p.archivePosts(postListToDelete, options); err != nil {
+			p.API.LogError("Unable to archive posts", "err", err)
+			p.settleQuota(options, 0)
+			return "", errors.New("Unable to archive the posts, so none of them has been deleted.")
+		}
+	}
+
+	// Nobody is there to undo a scheduled housecleaning
+	options.optNoUndo = true
+	result := p.deletePosts(postListToDelete, options, nil)
+	p.settleQuota(options, result.numPostsDeleted)
+	p.audit(options, result, archiveLink)
+
+	report := result.String()
+	if archiveLink != "" {
+		report += fmt.Sprintf("\nThe deleted posts have been [archived](%s).", archiveLink)
+	}
+
+	return report, nil
+}
+
+// getScheduledPosts retrieves the posts targeted by the subcommand of a schedule
+func (p *Plugin) getScheduledPosts(subcommand string, options *deletionOptions) (*model.PostList, error) {
+	switch subcommand {
+	case lastTrigger:
+		postList, appErr := p.getLastPosts(options.channelID, options.numPost)
+		if appErr != nil {
+			return nil, appErr
+		}
+		return postList, nil
+
+	case sinceTrigger:
+		postList, appErr := p.getPostsSince(options.channelID, options.sinceTime)
+		if appErr != nil {
+			return nil, appErr
+		}
+		return postList, nil
+	}
+
+	return nil, errors.Errorf("subcommand %s can't be scheduled", subcommand)
+}
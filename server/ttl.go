@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	// ttlExpiryInterval is the interval between two deletions of the expired posts
+	ttlExpiryInterval = time.Minute
+
+	// ttlRetryDelay is the delay before trying again to delete an expired post which could not be deleted
+	ttlRetryDelay = 10 * time.Minute
+
+	// ttlMaxAttempts is the number of attempts to delete an expired post before giving up
+	ttlMaxAttempts = 5
+)
+
+// channelTTL makes the new posts of a channel disappear after a while
+type channelTTL struct {
+	ChannelID  string `json:"channel_id"`
+	CreatorID  string `json:"creator_id"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	CreateAt   int64  `json:"create_at"`
+}
+
+// ttlSweep keeps track of a deletion of the expired posts
+type ttlSweep struct {
+	// authorProtections caches the protected authors of each channel
+	authorProtections map[string]func(userID string) bool
+
+	// results counts the deleted posts of each channel
+	results map[string]*deletePostResult
+}
+
+// expiringPost is a post waiting in the expiry queue
+type expiringPost struct {
+	PostID    string `json:"post_id"`
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id,omitempty"`
+	ExpireAt  int64  `json:"expire_at"`
+
+	// Attempts is the number of failed attempts to delete the post
+	Attempts int `json:"attempts,omitempty"`
+}
+
+func getChannelTTLKey(channelID string) string {
+	return kvTTLPrefix + channelID
+}
+
+// getTTLQueueKey returns the key of the expiry queue bucket containing the posts expiring
+// during the same minute as expireAt (in milliseconds)
+func getTTLQueueKey(expireAt int64) string {
+	return kvTTLQueuePrefix + strconv.FormatInt(expireAt/time.Minute.Milliseconds(), 10)
+}
+
+func (p *Plugin) saveChannelTTL(ttl *channelTTL) error {
+	_, err := p.client.KV.Set(getChannelTTLKey(ttl.ChannelID), ttl)
+	return err
+}
+
+// getChannelTTL returns the TTL of the channel, or nil if there is none
+func (p *Plugin) getChannelTTL(channelID string) (*channelTTL, error) {
+	var ttl *channelTTL
+	if err := p.client.KV.Get(getChannelTTLKey(channelID), &ttl); err != nil {
+		return nil, err
+	}
+
+	return ttl, nil
+}
+
+func (p *Plugin) deleteChannelTTL(channelID string) error {
+	return p.client.KV.Delete(getChannelTTLKey(channelID))
+}
+
+// MessageHasBeenPosted registers the new posts of the channels having a TTL in the expiry queue
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	ttl, err := p.getChannelTTL(post.ChannelId)
+	if err != nil {
+		p.API.LogError("Unable to get channel TTL", "ChannelID", post.ChannelId, "err", err)
+		return
+	}
+
+	if ttl == nil {
+		return
+	}
+
+	if err := p.queueExpiringPost(&expiringPost{
+		PostID:    post.Id,
+		ChannelID: post.ChannelId,
+		UserID:    post.UserId,
+		ExpireAt:  post.CreateAt + ttl.TTLSeconds*time.Second.Milliseconds(),
+	}); err != nil {
+		p.API.LogError("Unable to queue expiring post", "PostID", post.Id, "err", err)
+	}
+}
+
+// queueExpiringPost adds the post to the bucket of the expiry queue of its expiry minute.
+// A post already expired, like a restored or an imported one, goes to the bucket of the next minute,
+// because the sweep may have processed the past ones already
+func (p *Plugin) queueExpiringPost(post *expiringPost) error {
+	minute := time.Minute.Milliseconds()
+	post.ExpireAt = max(post.ExpireAt, (model.GetMillis()/minute+1)*minute)
+
+	return p.client.KV.SetAtomicWithRetries(getTTLQueueKey(post.ExpireAt), func(oldValue []byte) (any, error) {
+		var posts []*expiringPost
+		if len(oldValue) > 0 {
+			if err := json.Unmarshal(oldValue, &posts); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(posts, post), nil
+	})
+}
+
+// deleteExpiredPosts deletes the posts of the expiry queue whose TTL is over.
+// Only the buckets between the last processed minute and now are read.
+// It is run every minute as a background job, on a single server of the cluster at a time
+func (p *Plugin) deleteExpiredPosts() {
+	currentMinute := model.GetMillis() / time.Minute.Milliseconds()
+
+	nextMinute, err := p.getTTLQueueCursor(currentMinute)
+	if err != nil {
+		p.API.LogError("Unable to get the position in the expiry queue", "err", err)
+		return
+	}
+
+	sweep := &ttlSweep{
+		authorProtections: map[string]func(userID string) bool{},
+		results:           map[string]*deletePostResult{},
+	}
+	defer p.auditTTLSweep(sweep)
+
+	for ; nextMinute < currentMinute; nextMinute++ {
+		if err := p.processTTLQueueBucket(nextMinute, sweep); err != nil {
+			p.API.LogError("Unable to process the expiry queue", "minute", nextMinute, "err", err)
+			break // retried during the next run
+		}
+	}
+
+	if _, err := p.client.KV.Set(kvTTLQueueCursorKey, nextMinute); err != nil {
+		p.API.LogError("Unable to save the position in the expiry queue", "err", err)
+	}
+}
+
+// getTTLQueueCursor returns the first minute of the expiry queue not processed yet.
+// Without any saved position, it looks for the oldest bucket of the queue once
+func (p *Plugin) getTTLQueueCursor(currentMinute int64) (int64, error) {
+	var cursor int64
+	if err := p.client.KV.Get(kvTTLQueueCursorKey, &cursor); err != nil {
+		return 0, err
+	}
+
+	if cursor > 0 {
+		return cursor, nil
+	}
+
+	keys, err := p.listKVKeys(kvTTLQueuePrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor = currentMinute
+	for _, key := range keys {
+		if minute, err := strconv.ParseInt(strings.TrimPrefix(key, kvTTLQueuePrefix), 10, 64); err == nil {
+			cursor = min(cursor, minute)
+		}
+	}
+
+	return cursor, nil
+}
+
+// processTTLQueueBucket deletes the posts of the bucket of the expiry queue. The posts which can't be deleted
+// because of a technical error are queued again for a later retry, up to ttlMaxAttempts times
+func (p *Plugin) processTTLQueueBucket(minute int64, sweep *ttlSweep) error {
+	key := kvTTLQueuePrefix + strconv.FormatInt(minute, 10)
+
+	var posts []*expiringPost
+	if err := p.client.KV.Get(key, &posts); err != nil {
+		return err
+	}
+
+	if len(posts) == 0 {
+		return nil
+	}
+
+	retryAt := time.Now().Add(ttlRetryDelay).UnixMilli()
+	for _, post := range posts {
+		if p.deleteExpiredPost(post, sweep) {
+			continue
+		}
+
+		post.Attempts++
+		if post.Attempts >= ttlMaxAttempts {
+			p.API.LogError("Giving up deleting expired post", "PostID", post.PostID, "attempts", post.Attempts)
+			continue
+		}
+
+		post.ExpireAt = retryAt
+		if err := p.queueExpiringPost(post); err != nil {
+			return err
+		}
+	}
+
+	return p.client.KV.Delete(key)
+}
+
+// deleteExpiredPost deletes the post whose TTL is over, and counts it in the results of the sweep.
+// The posts of the protected channels and authors are kept for good, and leave the queue.
+// Returns false if the post could not be deleted because of a technical error, and has to be retried later
+func (p *Plugin) deleteExpiredPost(post *expiringPost, sweep *ttlSweep) bool {
+	result, ok := sweep.results[post.ChannelID]
+	if !ok {
+		result = new(deletePostResult)
+		sweep.results[post.ChannelID] = result
+	}
+
+	if protected, err := p.checkChannelProtected(post.ChannelID); err != nil {
+		result.technicalErrors++
+		p.API.LogError("Unable to check if the channel is protected", "ChannelID", post.ChannelID, "err", err)
+		return false
+	} else if protected {
+		return true
+	}
+
+	isAuthorProtected, ok := sweep.authorProtections[post.ChannelID]
+	if !ok {
+		var err error
+		if isAuthorProtected, err = p.loadAuthorProtection(post.ChannelID); err != nil {
+			result.technicalErrors++
+			p.API.LogError("Unable to get the protected authors", "ChannelID", post.ChannelID, "err", err)
+			return false
+		}
+		sweep.authorProtections[post.ChannelID] = isAuthorProtected
+	}
+	if isAuthorProtected(post.UserID) {
+		result.protectedPostErrors++
+		return true
+	}
+
+	appErr := p.API.DeletePost(post.PostID)
+	if appErr != nil {
+		// The post may have been deleted meanwhile, along with its thread
+		if appErr.StatusCode == http.StatusNotFound {
+			return true
+		}
+
+		result.technicalErrors++
+		p.API.LogError("Unable to delete expired post", "PostID", post.PostID, "appErr", appErr)
+		return false
+	}
+
+	result.numPostsDeleted++
+	return true
+}
+
+// auditTTLSweep keeps a single audit record per channel for the posts deleted by the sweep,
+// on behalf of the user who set up the TTL of the channel
+func (p *Plugin) auditTTLSweep(sweep *ttlSweep) {
+	for channelID, result := range sweep.results {
+		if result.numPostsDeleted == 0 {
+			continue
+		}
+
+		options := &deletionOptions{
+			channelID: channelID,
+			userID:    p.botUserID,
+			command:   "/broom " + ttlTrigger,
+		}
+
+		// The TTL may have been removed since the posts were queued
+		if ttl, err := p.getChannelTTL(channelID); err != nil {
+			p.API.LogWarn("Unable to get channel TTL", "ChannelID", channelID, "err", err)
+		} else if ttl != nil {
+			options.userID = ttl.CreatorID
+			options.command = fmt.Sprintf("/broom %s %s", ttlTrigger, time.Duration(ttl.TTLSeconds)*time.Second)
+		}
+
+		p.audit(options, result, "")
+	}
+}
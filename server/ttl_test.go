@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessTTLQueueBucket(t *testing.T) {
+	const bucketKey = kvTTLQueuePrefix + "100"
+
+	bucket, err := json.Marshal([]*expiringPost{
+		{PostID: "deleted", ChannelID: "channel", UserID: "user"},
+		{PostID: "gone", ChannelID: "channel", UserID: "user"},
+		{PostID: "protected", ChannelID: "channel", UserID: "releasebot"},
+		{PostID: "failing", ChannelID: "channel", UserID: "user"},
+		{PostID: "failingForLong", ChannelID: "channel", UserID: "user", Attempts: ttlMaxAttempts - 1},
+	})
+	require.NoError(t, err)
+
+	overrides, err := json.Marshal(&channelAuthorOverrides{ChannelID: "channel", ProtectedUserIDs: []string{"releasebot"}})
+	require.NoError(t, err)
+
+	isRetryBucket := func(key string) bool { return strings.HasPrefix(key, kvTTLQueuePrefix) && key != bucketKey }
+	serverError := model.NewAppError("DeletePost", "error", nil, "", http.StatusInternalServerError)
+
+	api := &plugintest.API{}
+	api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	api.On("KVGet", bucketKey).Return(bucket, nil)
+	api.On("KVGet", getProtectedChannelKey("channel")).Return(nil, nil)
+	api.On("KVGet", getProtectedAuthorsKey("channel")).Return(overrides, nil)
+	api.On("DeletePost", "deleted").Return(nil).Once()
+	api.On("DeletePost", "gone").Return(model.NewAppError("DeletePost", "not found", nil, "", http.StatusNotFound)).Once()
+	api.On("DeletePost", "failing").Return(serverError).Once()
+	api.On("DeletePost", "failingForLong").Return(serverError).Once()
+
+	// Only the post failing for the first time is queued again
+	api.On("KVGet", mock.MatchedBy(isRetryBucket)).Return(nil, nil).Once()
+	api.On("KVSetWithOptions", mock.MatchedBy(isRetryBucket), mock.MatchedBy(func(value []byte) bool {
+		var posts []*expiringPost
+		return json.Unmarshal(value, &posts) == nil && len(posts) == 1 && posts[0].PostID == "failing" && posts[0].Attempts == 1
+	}), mock.Anything).Return(true, nil).Once()
+	api.On("KVSetWithOptions", bucketKey, []byte(nil), mock.Anything).Return(true, nil).Once()
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.client = pluginapi.NewClient(api, nil)
+
+	sweep := &ttlSweep{
+		authorProtections: map[string]func(userID string) bool{},
+		results:           map[string]*deletePostResult{},
+	}
+	require.NoError(t, p.processTTLQueueBucket(100, sweep))
+
+	api.AssertNotCalled(t, "DeletePost", "protected")
+	result := sweep.results["channel"]
+	require.NotNil(t, result)
+	assert.Equal(t, 1, result.numPostsDeleted)
+	assert.Equal(t, 1, result.protectedPostErrors)
+	assert.Equal(t, 2, result.technicalErrors)
+}
+
+func TestGetTTLQueueCursor(t *testing.T) {
+	const currentMinute = 1000
+
+	for name, tc := range map[string]struct {
+		savedCursor []byte
+		keys        []string
+		expected    int64
+	}{
+		"saved cursor": {
+			savedCursor: []byte("900"),
+			expected:    900,
+		},
+		"oldest bucket": {
+			keys:     []string{kvTTLQueuePrefix + "950", kvTTLQueuePrefix + "920", kvTTLPrefix + "channel"},
+			expected: 920,
+		},
+		"empty queue": {
+			keys:     []string{kvTTLPrefix + "channel"},
+			expected: currentMinute,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("KVGet", kvTTLQueueCursorKey).Return(tc.savedCursor, nil)
+			if tc.savedCursor == nil {
+				api.On("KVList", 0, kvListPerPage).Return(tc.keys, nil).Once()
+			}
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+
+			cursor, err := p.getTTLQueueCursor(currentMinute)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cursor)
+		})
+	}
+}
+
+func TestQueueExpiringPost(t *testing.T) {
+	nextMinute := time.Now().Unix()/60 + 1
+
+	// A restored post keeps its creation date, so it expired long ago
+	isNextBucket := func(key string) bool {
+		minute, err := strconv.ParseInt(strings.TrimPrefix(key, kvTTLQueuePrefix), 10, 64)
+		return err == nil && minute >= nextMinute && minute <= nextMinute+1
+	}
+
+	api := &plugintest.API{}
+	api.On("KVGet", mock.MatchedBy(isNextBucket)).Return(nil, nil).Once()
+	api.On("KVSetWithOptions", mock.MatchedBy(isNextBucket), mock.Anything, mock.Anything).Return(true, nil).Once()
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.client = pluginapi.NewClient(api, nil)
+
+	require.NoError(t, p.queueExpiringPost(&expiringPost{PostID: "restored", ChannelID: "channel", ExpireAt: 1000}))
+}
@@ -1,20 +1,41 @@
 package main
 
 import (
-	"strings"
-
 	"github.com/mattermost/mattermost/server/public/model"
 )
 
 // Checks if the user has sysadmin permission
 func isSysadmin(p *Plugin, userID string) bool {
-	user, appErr := p.API.GetUser(userID)
-	if appErr != nil {
-		p.API.LogError("Unable to get user", "err", appErr)
-		return false
+	return p.API.HasPermissionTo(userID, model.PermissionManageSystem)
+}
+
+// Checks if the user has one of the roles allowed to use /broom in the channel.
+// The admins of a higher level are always allowed
+func isAllowedToBroom(p *Plugin, userID string, teamID string, channelID string) bool {
+	switch p.getConfiguration().getAllowedRoles() {
+	case allowedRolesSystemAdmin:
+		return isSysadmin(p, userID)
+	case allowedRolesTeamAdmin:
+		return p.API.HasPermissionToTeam(userID, teamID, model.PermissionManageTeam)
+	case allowedRolesChannelAdmin:
+		return p.API.HasPermissionToChannel(userID, channelID, model.PermissionManageChannelRoles)
+	}
+
+	return true
+}
+
+// Returns who is allowed to use /broom, to be shown to the users who are not
+func getAllowedRolesDisplayName(allowedRoles string) string {
+	switch allowedRoles {
+	case allowedRolesSystemAdmin:
+		return "system admins"
+	case allowedRolesTeamAdmin:
+		return "team admins"
+	case allowedRolesChannelAdmin:
+		return "channel admins"
 	}
 
-	return strings.Contains(user.Roles, "system_admin")
+	return "members"
 }
 
 // Checks if the user has the "delete_post" permission
@@ -29,6 +50,26 @@ func canDeleteOthersPosts(p *Plugin, userID string, channelID string) bool {
 		p.API.HasPermissionToChannel(userID, channelID, model.PermissionDeleteOthersPosts)
 }
 
+// Returns the @mention of the user, or its ID if the user can't be found
+func (p *Plugin) getUserMention(userID string) string {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return userID
+	}
+
+	return "@" + user.Username
+}
+
+// Returns the name of the channel, to be used in a ~mention, or its ID if the channel can't be found
+func (p *Plugin) getChannelName(channelID string) string {
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return channelID
+	}
+
+	return channel.Name
+}
+
 // Returns "s" if the given number is > 1
 func getPluralChar(number int) string {
 	if 1 < number {
@@ -38,6 +79,38 @@ func getPluralChar(number int) string {
 	return ""
 }
 
+// Returns singular if the given number is <= 1, plural otherwise
+func getPluralSuffix(number int, singular string, plural string) string {
+	if 1 < number {
+		return plural
+	}
+
+	return singular
+}
+
+// Tells if the slice contains the value
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns a copy of the slice without the value
+func removeValue(slice []string, value string) []string {
+	result := []string{}
+	for _, item := range slice {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
 // Simplified version of SendEphemeralPost, send to the userID defined
 func (p *Plugin) sendEphemeralPost(userID string, channelID string, message string) *model.Post {
 	return p.API.SendEphemeralPost(
@@ -52,15 +125,26 @@ func (p *Plugin) respondEphemeralResponse(args *model.CommandArgs, message strin
 	return &model.CommandResponse{}
 }
 
+// Wrapper of p.sendEphemeralPost() to one-line the return statements when the command args are not available anymore
+func (p *Plugin) respondEphemeralPost(options *deletionOptions, message string) *model.CommandResponse {
+	_ = p.sendEphemeralPost(options.userID, options.channelID, message)
+	return &model.CommandResponse{}
+}
+
 // Tells if the plugin should has for the confirmation of deletion
-func (p *Plugin) shouldConfirmDeletion(optNoConfirmDialog bool) bool {
+func (p *Plugin) shouldConfirmDeletion(options *deletionOptions) bool {
 	conf := p.getConfiguration()
 
+	if options.optDryRun {
+		// Nothing will be deleted
+		return false
+	}
+
 	if conf.AskConfirm == askConfirmNever {
 		return false
 	}
 
-	if conf.AskConfirm == askConfirmOptional && optNoConfirmDialog {
+	if conf.AskConfirm == askConfirmOptional && options.optNoConfirmDialog {
 		return false
 	}
 
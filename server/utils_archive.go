@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	archiveFormatJSON     = "json"
+	archiveFormatCSV      = "csv"
+	archiveFormatMarkdown = "markdown"
+)
+
+var archiveFormatExtensions = map[string]string{
+	archiveFormatJSON:     "json",
+	archiveFormatCSV:      "csv",
+	archiveFormatMarkdown: "md",
+}
+
+// archivedPost is the representation of a deleted post in an archive
+type archivedPost struct {
+	ID        string             `json:"id"`
+	CreateAt  int64              `json:"create_at"`
+	UpdateAt  int64              `json:"update_at"`
+	EditAt    int64              `json:"edit_at"`
+	UserID    string             `json:"user_id"`
+	Username  string             `json:"username"`
+	ChannelID string             `json:"channel_id"`
+	RootID    string             `json:"root_id"`
+	Type      string             `json:"type"`
+	Message   string             `json:"message"`
+	IsPinned  bool               `json:"is_pinned"`
+	FileIDs   []string           `json:"file_ids"`
+	Reactions []archivedReaction `json:"reactions"`
+}
+
+type archivedReaction struct {
+	EmojiName string `json:"emoji_name"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	CreateAt  int64  `json:"create_at"`
+}
+
+// archivePosts serializes the posts of postList that are about to be deleted, and uploads the archive
+// to the archive channel, or sends it to the user by direct message from the bot.
+// Returns the permalink to the post containing the archive
+func (p *Plugin) archivePosts(postList *model.PostList, options *deletionOptions) (string, error) {
+	selected := p.selectPostsToDelete(postList, options, new(deletePostResult))
+
+	postsToArchive, err := p.getPostsToArchive(selected)
+	if err != nil {
+		return "", err
+	}
+
+	posts, err := p.getArchivedPosts(postsToArchive)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := serializeArchive(posts, options.archiveFormat)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to serialize archive")
+	}
+
+	archiveChannelID, err := p.getArchiveChannelID(options.userID)
+	if err != nil {
+		return "", err
+	}
+
+	channel, appErr := p.API.GetChannel(options.channelID)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to get channel")
+	}
+
+	filename := fmt.Sprintf(
+		"broom-%s-%s.%s",
+		channel.Name, time.Now().UTC().Format("20060102-150405"),
+		archiveFormatExtensions[options.archiveFormat],
+	)
+
+	fileInfo, appErr := p.API.UploadFile(data, archiveChannelID, filename)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to upload archive")
+	}
+
+	post, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: archiveChannelID,
+		Message: fmt.Sprintf(
+			"Archive of %d post%s deleted by %s in **%s**.",
+			len(posts), getPluralChar(len(posts)), p.getUserMention(options.userID), channel.DisplayName,
+		),
+		FileIds: model.StringArray{fileInfo.Id},
+	})
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to post archive")
+	}
+
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	return fmt.Sprintf("%s/_redirect/pl/%s", *siteURL, post.Id), nil
+}
+
+// getArchiveChannelID returns the configured archive channel, or the direct channel between the user and the bot
+func (p *Plugin) getArchiveChannelID(userID string) (string, error) {
+	if archiveChannelID := p.getConfiguration().ArchiveChannelID; archiveChannelID != "" {
+		if _, appErr := p.API.GetChannel(archiveChannelID); appErr != nil {
+			return "", errors.Wrap(appErr, "failed to get archive channel")
+		}
+		return archiveChannelID, nil
+	}
+
+	directChannel, appErr := p.API.GetDirectChannel(userID, p.botUserID)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to get direct channel with the bot")
+	}
+
+	return directChannel.Id, nil
+}
+
+// getPostsToArchive returns the selected posts that deletePosts deletes, sorted from the most recent one:
+// the root posts kept for the other replies of their thread are left out, and the replies deleted
+// along with their root post are added. A thread is only fetched if some of its replies are not selected
+func (p *Plugin) getPostsToArchive(selected *model.PostList) (*model.PostList, error) {
+	p.fillMissingReplyCounts(selected)
+	keptRoots := getKeptRoots(selected)
+
+	selectedReplies := map[string][]*model.Post{}
+	for _, postID := range selected.Order {
+		if post := selected.Posts[postID]; post.RootId != "" {
+			selectedReplies[post.RootId] = append(selectedReplies[post.RootId], post)
+		}
+	}
+
+	postList := model.NewPostList()
+	for _, postID := range selected.Order {
+		if keptRoots[postID] {
+			continue // process next post
+		}
+
+		posts, err := p.getPostWithThread(selected.Posts[postID], selectedReplies[postID])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get thread of post %s", postID)
+		}
+
+		for _, post := range posts {
+			if _, ok := postList.Posts[post.Id]; !ok {
+				postList.AddPost(post)
+				postList.AddOrder(post.Id)
+			}
+		}
+	}
+
+	postList.SortByCreateAt()
+	return postList, nil
+}
+
+// getArchivedPosts converts the posts to archive, from the oldest to the most recent one
+func (p *Plugin) getArchivedPosts(postList *model.PostList) ([]*archivedPost, error) {
+	usernames := map[string]string{}
+	getUsername := func(userID string) string {
+		if username, ok := usernames[userID]; ok {
+			return username
+		}
+
+		username := ""
+		if user, appErr := p.API.GetUser(userID); appErr == nil {
+			username = user.Username
+		}
+		usernames[userID] = username
+		return username
+	}
+
+	posts := make([]*archivedPost, 0, len(postList.Order))
+	for i := len(postList.Order) - 1; i >= 0; i-- {
+		post := postList.Posts[postList.Order[i]]
+
+		reactions, appErr := p.API.GetReactions(post.Id)
+		if appErr != nil {
+			return nil, errors.Wrapf(appErr, "failed to get reactions of post %s", post.Id)
+		}
+
+		archivedReactions := make([]archivedReaction, 0, len(reactions))
+		for _, reaction := range reactions {
+			archivedReactions = append(archivedReactions, archivedReaction{
+				EmojiName: reaction.EmojiName,
+				UserID:    reaction.UserId,
+				Username:  getUsername(reaction.UserId),
+				CreateAt:  reaction.CreateAt,
+			})
+		}
+
+		posts = append(posts, &archivedPost{
+			ID:        post.Id,
+			CreateAt:  post.CreateAt,
+			UpdateAt:  post.UpdateAt,
+			EditAt:    post.EditAt,
+			UserID:    post.UserId,
+			Username:  getUsername(post.UserId),
+			ChannelID: post.ChannelId,
+			RootID:    post.RootId,
+			Type:      post.Type,
+			Message:   post.Message,
+			IsPinned:  post.IsPinned,
+			FileIDs:   post.FileIds,
+			Reactions: archivedReactions,
+		})
+	}
+
+	return posts, nil
+}
+
+// serializeArchive writes the archived posts in the given format
+func serializeArchive(posts []*archivedPost, format string) ([]byte, error) {
+	switch format {
+	case archiveFormatJSON:
+		return json.MarshalIndent(posts, "", "  ")
+
+	case archiveFormatCSV:
+		var buffer bytes.Buffer
+		writer := csv.NewWriter(&buffer)
+
+		_ = writer.Write([]string{
+			"id", "create_at", "update_at", "edit_at", "user_id", "username", "channel_id",
+			"root_id", "type", "message", "is_pinned", "file_ids", "reactions",
+		})
+		for _, post := range posts {
+			reactions := make([]string, 0, len(post.Reactions))
+			for _, reaction := range post.Reactions {
+				reactions = append(reactions, reaction.EmojiName+":"+reaction.Username)
+			}
+
+			_ = writer.Write([]string{
+				post.ID,
+				strconv.FormatInt(post.CreateAt, 10),
+				strconv.FormatInt(post.UpdateAt, 10),
+				strconv.FormatInt(post.EditAt, 10),
+				post.UserID,
+				post.Username,
+				post.ChannelID,
+				post.RootID,
+				post.Type,
+				post.Message,
+				strconv.FormatBool(post.IsPinned),
+				strings.Join(post.FileIDs, " "),
+				strings.Join(reactions, " "),
+			})
+		}
+
+		writer.Flush()
+		return buffer.Bytes(), writer.Error()
+
+	case archiveFormatMarkdown:
+		var builder strings.Builder
+		for _, post := range posts {
+			fmt.Fprintf(&builder, "### @%s — %s\n", post.Username, time.UnixMilli(post.CreateAt).UTC().Format(time.RFC3339))
+			fmt.Fprintf(&builder, "_Post `%s`", post.ID)
+			if post.RootID != "" {
+				fmt.Fprintf(&builder, ", reply to `%s`", post.RootID)
+			}
+			builder.WriteString("_\n\n")
+			builder.WriteString(post.Message + "\n\n")
+
+			if len(post.FileIDs) > 0 {
+				fmt.Fprintf(&builder, "Files: `%s`\n\n", strings.Join(post.FileIDs, "`, `"))
+			}
+
+			if len(post.Reactions) > 0 {
+				reactions := make([]string, 0, len(post.Reactions))
+				for _, reaction := range post.Reactions {
+					reactions = append(reactions, fmt.Sprintf(":%s: @%s", reaction.EmojiName, reaction.Username))
+				}
+				fmt.Fprintf(&builder, "Reactions: %s\n\n", strings.Join(reactions, ", "))
+			}
+		}
+		return []byte(builder.String()), nil
+	}
+
+	return nil, errors.Errorf("unknown archive format %s", format)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPostsToArchive(t *testing.T) {
+	for name, tc := range map[string]struct {
+		posts    []*model.Post
+		thread   []*model.Post
+		expected []string
+	}{
+		"whole thread selected": {
+			posts: []*model.Post{
+				{Id: "other", CreateAt: 4},
+				{Id: "reply2", RootId: "root", CreateAt: 3},
+				{Id: "reply1", RootId: "root", CreateAt: 2},
+				{Id: "root", ReplyCount: 2, CreateAt: 1},
+			},
+			expected: []string{"other", "reply2", "reply1", "root"},
+		},
+		"root kept for the replies not selected": {
+			posts: []*model.Post{
+				{Id: "reply1", RootId: "root", CreateAt: 2},
+				{Id: "root", ReplyCount: 2, CreateAt: 1},
+			},
+			expected: []string{"reply1"},
+		},
+		"missing reply count": {
+			posts: []*model.Post{
+				{Id: "reply2", RootId: "root", CreateAt: 3},
+				{Id: "root", CreateAt: 1},
+			},
+			thread: []*model.Post{
+				{Id: "root", CreateAt: 1},
+				{Id: "reply1", RootId: "root", CreateAt: 2},
+				{Id: "reply2", RootId: "root", CreateAt: 3},
+			},
+			expected: []string{"reply2"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			if tc.thread != nil {
+				thread := model.NewPostList()
+				for _, post := range tc.thread {
+					thread.AddPost(post)
+					thread.AddOrder(post.Id)
+				}
+				api.On("GetPostThread", "root").Return(thread, nil).Once()
+			}
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+
+			selected := model.NewPostList()
+			for _, post := range tc.posts {
+				selected.AddPost(post)
+				selected.AddOrder(post.Id)
+			}
+
+			postList, err := p.getPostsToArchive(selected)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, postList.Order)
+		})
+	}
+}
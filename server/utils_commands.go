@@ -1,13 +1,29 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/pkg/errors"
 )
 
+// maxMatchLength is the maximum length of the regular expression given to --match
+const maxMatchLength = 200
+
+// Post types accepted by --type
+const (
+	postTypeSystem    = "system"
+	postTypeJoinLeave = "join-leave"
+	postTypeWebhook   = "webhook"
+	postTypeBot       = "bot"
+	postTypeUser      = "user"
+)
+
 // userError defines an error made by the user (incorrect argument, etc).
 // It should not be logged by the server, but sent back to the user
 type userError error
@@ -17,26 +33,213 @@ type deletionOptions struct {
 	channelID             string
 	userID                string
 	triggerID             string
+	command               string
 	numPost               int
+	sinceTime             int64
+	untilTime             int64
+	fromPostID            string
+	moveChannelID         string
+	rootID                string
+	jobID                 string
+	schedule              *scheduleOptions
+	retention             *retentionOptions
+	ttl                   *ttlOptions
+	history               *historyOptions
+	filters               postFilters
 	optDeletePinnedPosts  bool
 	optNoConfirmDialog    bool
+	optDryRun             bool
+	optIncludeRoot        bool
+	archiveFormat         string
+	optNoUndo             bool
 	permDeleteOthersPosts bool
+
+	// quotaReservation holds the posts counted in the daily usage of the user before deleting them
+	quotaReservation *quotaReservation
+}
+
+// postFilters contains the criteria restricting which posts are deleted.
+// It is serialized in the state of the confirmation dialogs
+type postFilters struct {
+	UserIDs    []string `json:"user_ids,omitempty"`
+	NotUserIDs []string `json:"not_user_ids,omitempty"`
+	Match      string   `json:"match,omitempty"`
+	Contains   string   `json:"contains,omitempty"`
+	Types      []string `json:"types,omitempty"`
+
+	// matchRegexp is Match compiled, lazily
+	matchRegexp *regexp.Regexp
+}
+
+// matches tells if the post satisfies all the filters
+func (filters *postFilters) matches(post *model.Post) bool {
+	if len(filters.UserIDs) > 0 && !contains(filters.UserIDs, post.UserId) {
+		return false
+	}
+
+	if contains(filters.NotUserIDs, post.UserId) {
+		return false
+	}
+
+	if len(filters.Types) > 0 && !matchesAnyType(post, filters.Types) {
+		return false
+	}
+
+	if filters.Contains != "" && !strings.Contains(strings.ToLower(post.Message), strings.ToLower(filters.Contains)) {
+		return false
+	}
+
+	if filters.Match != "" {
+		if filters.matchRegexp == nil {
+			matchRegexp, err := compileMatch(filters.Match)
+			if err != nil {
+				return false
+			}
+			filters.matchRegexp = matchRegexp
+		}
+
+		if !filters.matchRegexp.MatchString(post.Message) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesAnyType tells if the post is of one of the given types
+func matchesAnyType(post *model.Post, types []string) bool {
+	fromWebhook := post.GetProp(model.PostPropsFromWebhook) == "true"
+	// The server marks the posts of the bot accounts
+	fromBot := post.GetProp(model.PostPropsFromBot) == "true"
+
+	for _, postType := range types {
+		switch postType {
+		case postTypeSystem:
+			if post.IsSystemMessage() {
+				return true
+			}
+		case postTypeJoinLeave:
+			if post.IsJoinLeaveMessage() {
+				return true
+			}
+		case postTypeWebhook:
+			if fromWebhook {
+				return true
+			}
+		case postTypeBot:
+			if fromBot {
+				return true
+			}
+		case postTypeUser:
+			if !post.IsSystemMessage() && !fromWebhook && !fromBot {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hasTextFilter tells if the posts are filtered on their message
+func (filters *postFilters) hasTextFilter() bool {
+	return filters.Match != "" || filters.Contains != ""
+}
+
+// getMatchedCountText tells how many posts of postList satisfy the filters, to be shown in the confirmation dialogs
+func (filters *postFilters) getMatchedCountText(postList *model.PostList) string {
+	numMatched := 0
+	for _, postID := range postList.Order {
+		if filters.matches(postList.Posts[postID]) {
+			numMatched++
+		}
+	}
+
+	return fmt.Sprintf(
+		"**%d** of the %d post%s match the filters.",
+		numMatched, len(postList.Order), getPluralChar(len(postList.Order)),
+	)
+}
+
+// compileMatch compiles the regular expression given to --match.
+// Go regular expressions run in linear time, so only their length has to be limited
+func compileMatch(expr string) (*regexp.Regexp, error) {
+	if len(expr) > maxMatchLength {
+		return nil, errors.Errorf("the regular expression should not be longer than %d characters", maxMatchLength)
+	}
+
+	return regexp.Compile(expr)
+}
+
+// dialogState is the state of a confirmation dialog, used to restore the options when it is submitted
+type dialogState struct {
+	Value         string      `json:"value"`
+	Filters       postFilters `json:"filters"`
+	ArchiveFormat string      `json:"archive_format,omitempty"`
+	Command       string      `json:"command,omitempty"`
+}
+
+// getDialogState serializes value, the argument of the subcommand, along with the options to keep in the dialog
+func (options *deletionOptions) getDialogState(value string) string {
+	state, _ := json.Marshal(dialogState{
+		Value:         value,
+		Filters:       options.filters,
+		ArchiveFormat: options.archiveFormat,
+		Command:       options.command,
+	})
+
+	return string(state)
+}
+
+// parseDialogState restores the state serialized by getDialogState.
+// The state comes back from the client, so its options are checked again
+func parseDialogState(state string) (*dialogState, error) {
+	var parsed dialogState
+	if err := json.Unmarshal([]byte(state), &parsed); err != nil {
+		return nil, err
+	}
+
+	if _, ok := archiveFormatExtensions[parsed.ArchiveFormat]; parsed.ArchiveFormat != "" && !ok {
+		return nil, errors.Errorf("invalid archive format %q", parsed.ArchiveFormat)
+	}
+
+	return &parsed, nil
 }
 
 // Returns the subcommand, the sanitized options, and a userError if applicable
 func (p *Plugin) parseAndCheckCommandArgs(args *model.CommandArgs) (string, *deletionOptions, userError) {
+	subcommand, options, userErr := p.parseCommandArgs(args)
+	if userErr != nil || options == nil {
+		return subcommand, options, userErr
+	}
+
+	// The number of posts is known in advance for some subcommands, check it early.
+	// A dry run doesn't delete anything, so it is not limited
+	if options.numPost > 0 && !options.optDryRun {
+		if userErr = p.checkQuota(options.userID, options.numPost); userErr != nil {
+			return subcommand, nil, userErr
+		}
+	}
+
+	// All is good!
+	return subcommand, options, nil
+}
+
+// parseCommandArgs is like parseAndCheckCommandArgs, but only checks the syntax of the command, not the quota
+func (p *Plugin) parseCommandArgs(args *model.CommandArgs) (string, *deletionOptions, userError) {
 	subcommand := ""
 	options := &deletionOptions{
 		channelID:             args.ChannelId,
 		userID:                args.UserId,
 		triggerID:             args.TriggerId,
+		command:               args.Command,
 		numPost:               0,
 		permDeleteOthersPosts: canDeleteOthersPosts(p, args.UserId, args.ChannelId),
 		optDeletePinnedPosts:  false,
 		optNoConfirmDialog:    false,
 	}
 
-	split := strings.Fields(args.Command)
+	split := splitCommandArgs(args.Command)
+	positionalArgs := []string{}
 
 	for i := 1; i < len(split); i++ { // Initialize to 1 to skip '/broom'
 		if i == 1 {
@@ -45,6 +248,18 @@ func (p *Plugin) parseAndCheckCommandArgs(args *model.CommandArgs) (string, *del
 				return subcommand, nil, nil
 			}
 
+			if subcommand == scheduleTrigger {
+				// The arguments of the scheduled command are kept as is
+				if userErr := p.parseScheduleArgs(args, split[2:], options); userErr != nil {
+					return subcommand, nil, userErr
+				}
+				return subcommand, options, nil
+			}
+
+			if subcommand == historyTrigger {
+				options.history = &historyOptions{limit: defaultHistoryLimit}
+			}
+
 			continue
 		}
 
@@ -60,7 +275,7 @@ func (p *Plugin) parseAndCheckCommandArgs(args *model.CommandArgs) (string, *del
 			}
 			argValue := split[i]
 
-			_, argValueBool, userErr := processNamedArgValue(p, argName, argValue, options)
+			argValueString, argValueBool, userErr := processNamedArgValue(p, argName, argValue, options)
 			if userErr != nil {
 				return subcommand, nil, userErr
 			}
@@ -70,41 +285,112 @@ func (p *Plugin) parseAndCheckCommandArgs(args *model.CommandArgs) (string, *del
 				options.optDeletePinnedPosts = *argValueBool
 			case argNoConfirm:
 				options.optNoConfirmDialog = *argValueBool
+			case argDryRun:
+				options.optDryRun = *argValueBool
+			case argIncludeRoot:
+				options.optIncludeRoot = *argValueBool
+			case argArchive:
+				options.archiveFormat = *argValueString
+			case argUser:
+				options.filters.UserIDs = append(options.filters.UserIDs, *argValueString)
+			case argNotUser:
+				options.filters.NotUserIDs = append(options.filters.NotUserIDs, *argValueString)
+			case argMatch:
+				options.filters.Match = *argValueString
+			case argContains:
+				options.filters.Contains = *argValueString
+			case argType:
+				options.filters.Types = append(options.filters.Types, *argValueString)
+			case argChannel:
+				options.history.channelName = *argValueString
+			case argLimit:
+				options.history.limit, _ = strconv.Atoi(*argValueString)
 			}
 
 			continue // i has been incremented already to skip the value of the named argument
 		}
 
-		// Number of post to delete
-		if options.numPost != 0 {
-			return "", nil, errors.Errorf("Invalid argument `%s`", split[i])
-		}
+		positionalArgs = append(positionalArgs, split[i])
+	}
 
-		numPostToDelete64, err := strconv.ParseInt(split[i], 10, 0)
-		if err != nil {
-			return subcommand, nil, errors.Errorf("Incorrect argument. [number-of-post] must be an integer")
-		}
+	var userErr userError
+	switch subcommand {
+	case lastTrigger:
+		userErr = p.parseLastArgs(args, positionalArgs, options)
+	case sinceTrigger:
+		userErr = p.parseSinceArgs(args, positionalArgs, options)
+	case betweenTrigger:
+		userErr = p.parseBetweenArgs(args, positionalArgs, options)
+	case fromTrigger:
+		userErr = p.parseFromArgs(args, positionalArgs, options)
+	case undoTrigger:
+		userErr = p.parseUndoArgs(positionalArgs)
+	case moveTrigger:
+		userErr = p.parseMoveArgs(args, positionalArgs, options)
+	case threadTrigger:
+		userErr = p.parseThreadArgs(args, positionalArgs, options)
+	case statusTrigger:
+		userErr = p.parseStatusArgs(positionalArgs)
+	case cancelTrigger:
+		userErr = p.parseCancelArgs(positionalArgs, options)
+	case retentionTrigger:
+		userErr = p.parseRetentionArgs(positionalArgs, options)
+	case ttlTrigger:
+		userErr = p.parseTTLArgs(positionalArgs, options)
+	case protectTrigger, unprotectTrigger:
+		userErr = p.parseProtectArgs(positionalArgs)
+	case historyTrigger:
+		userErr = p.parseHistoryArgs(args, positionalArgs, options)
+	}
+	if userErr != nil {
+		return subcommand, nil, userErr
+	}
 
-		if numPostToDelete64 < 1 {
-			return subcommand, nil, errors.Errorf("You may want to delete at least one post :wink: ")
-		}
+	return subcommand, options, nil
+}
 
-		currentChannel, appErr := p.API.GetChannel(args.ChannelId)
-		if appErr != nil {
-			p.API.LogError("Unable to get channel statistics", "appErr", appErr)
-			return subcommand, nil, errors.Errorf("Error when deleting posts")
-		}
+// splitCommandArgs splits the command into arguments separated by spaces.
+// Spaces between double quotes are kept, and the quotes are removed
+func splitCommandArgs(command string) []string {
+	args := []string{}
+	var current strings.Builder
+	inQuotes, inArg := false, false
 
-		if currentChannel.TotalMsgCount < numPostToDelete64 {
-			// stop the command because if numPostToDelete > currentChannel.TotalMsgCount, the plugin crashes
-			return subcommand, nil, errors.Errorf("Cannot delete more posts that there is in this channel")
+	for _, char := range command {
+		switch {
+		case char == '"' || char == '“' || char == '”':
+			inQuotes = !inQuotes
+			inArg = true
+		case unicode.IsSpace(char) && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(char)
+			inArg = true
 		}
+	}
 
-		options.numPost = int(numPostToDelete64)
+	if inArg {
+		args = append(args, current.String())
 	}
 
-	// All is good!
-	return subcommand, options, nil
+	return args
+}
+
+// joinCommandArgs is the reverse of splitCommandArgs, quoting the arguments containing spaces
+func joinCommandArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsFunc(arg, unicode.IsSpace) {
+			arg = `"` + arg + `"`
+		}
+		quoted = append(quoted, arg)
+	}
+
+	return strings.Join(quoted, " ")
 }
 
 // Processes a named arg defined for this command and check its value
@@ -125,6 +411,85 @@ func processNamedArgValue(p *Plugin, argName string, argValue string, existingOp
 			return nil, &value, nil
 		}
 		return nil, nil, errors.Errorf("Invalid value for `--%s`, `%s` should be `true` or `false`", argName, argValue)
+
+	// --------------------------------------------
+	case argIncludeRoot:
+		if argValue == "true" || argValue == "false" {
+			value := argValue == "true"
+			return nil, &value, nil
+		}
+		return nil, nil, errors.Errorf("Invalid value for `--%s`, `%s` should be `true` or `false`", argName, argValue)
+
+	// --------------------------------------------
+	case argDryRun:
+		if argValue == "true" || argValue == "false" {
+			value := argValue == "true"
+			return nil, &value, nil
+		}
+		return nil, nil, errors.Errorf("Invalid value for `--%s`, `%s` should be `true` or `false`", argName, argValue)
+
+	// --------------------------------------------
+	case argArchive:
+		if _, ok := archiveFormatExtensions[argValue]; ok {
+			return &argValue, nil, nil
+		}
+		return nil, nil, errors.Errorf(
+			"Invalid value for `--%s`, `%s` should be `%s`, `%s` or `%s`",
+			argName, argValue, archiveFormatJSON, archiveFormatCSV, archiveFormatMarkdown,
+		)
+
+	// --------------------------------------------
+	case argUser, argNotUser:
+		user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(argValue, "@"))
+		if appErr != nil {
+			return nil, nil, errors.Errorf("Invalid value for `--%s`, user `%s` not found", argName, argValue)
+		}
+		return &user.Id, nil, nil
+
+	// --------------------------------------------
+	case argMatch:
+		if _, err := compileMatch(argValue); err != nil {
+			return nil, nil, errors.Errorf("Invalid value for `--%s`, %s", argName, err.Error())
+		}
+		return &argValue, nil, nil
+
+	// --------------------------------------------
+	case argContains:
+		if argValue == "" {
+			return nil, nil, errors.Errorf("Invalid value for `--%s`, the text should not be empty", argName)
+		}
+		return &argValue, nil, nil
+
+	// --------------------------------------------
+	case argType:
+		switch argValue {
+		case postTypeSystem, postTypeJoinLeave, postTypeWebhook, postTypeBot, postTypeUser:
+			return &argValue, nil, nil
+		}
+		return nil, nil, errors.Errorf(
+			"Invalid value for `--%s`, `%s` should be `%s`, `%s`, `%s`, `%s` or `%s`",
+			argName, argValue, postTypeSystem, postTypeJoinLeave, postTypeWebhook, postTypeBot, postTypeUser,
+		)
+
+	// --------------------------------------------
+	case argChannel:
+		if existingOptions.history == nil {
+			return nil, nil, errors.Errorf("Argument `--%s` can only be used with `/broom %s`", argName, historyTrigger)
+		}
+		if argValue == "" {
+			return nil, nil, errors.Errorf("Invalid value for `--%s`, the channel should not be empty", argName)
+		}
+		return &argValue, nil, nil
+
+	// --------------------------------------------
+	case argLimit:
+		if existingOptions.history == nil {
+			return nil, nil, errors.Errorf("Argument `--%s` can only be used with `/broom %s`", argName, historyTrigger)
+		}
+		if limit, err := strconv.Atoi(argValue); err != nil || limit < 1 || limit > maxHistoryLimit {
+			return nil, nil, errors.Errorf("Invalid value for `--%s`, `%s` should be a number between 1 and %d", argName, argValue, maxHistoryLimit)
+		}
+		return &argValue, nil, nil
 	}
 
 	// --------------------------------------------
@@ -143,6 +508,54 @@ func addAllNamedTextArgumentsToCmd(cmd *model.AutocompleteData, skipConfirmation
 		},
 	})
 
+	cmd.AddNamedDynamicListArgument(argUser, "Only delete the posts of this user (can be repeated)", routeAutocompleteUsers, false)
+	cmd.AddNamedDynamicListArgument(argNotUser, "Do not delete the posts of this user (can be repeated)", routeAutocompleteUsers, false)
+
+	cmd.AddNamedTextArgument(argMatch, "Only delete the posts matching this regular expression", "\"regex\"", "", false)
+	cmd.AddNamedTextArgument(argContains, "Only delete the posts containing this text, ignoring case", "\"text\"", "", false)
+
+	cmd.AddNamedStaticListArgument(argType, "Only delete the posts of this type (can be repeated)", false, []model.AutocompleteListItem{
+		{
+			Item:     postTypeSystem,
+			HelpText: "System messages, like header changes and join/leave messages",
+		}, {
+			Item:     postTypeJoinLeave,
+			HelpText: "Messages about users joining or leaving the channel or the team",
+		}, {
+			Item:     postTypeWebhook,
+			HelpText: "Posts created by incoming webhooks",
+		}, {
+			Item:     postTypeBot,
+			HelpText: "Posts of bot accounts",
+		}, {
+			Item:     postTypeUser,
+			HelpText: "Posts written by users",
+		},
+	})
+
+	cmd.AddNamedStaticListArgument(argDryRun, "Only show what would be deleted", false, []model.AutocompleteListItem{
+		{
+			Item:     "true",
+			HelpText: "Do not delete anything, list the posts that would be deleted",
+		}, {
+			Item:     "false",
+			HelpText: "Delete the posts (default behavior)",
+		},
+	})
+
+	cmd.AddNamedStaticListArgument(argArchive, "Archive the posts in a file before deleting them", false, []model.AutocompleteListItem{
+		{
+			Item:     archiveFormatJSON,
+			HelpText: "Archive the posts in a JSON file",
+		}, {
+			Item:     archiveFormatCSV,
+			HelpText: "Archive the posts in a CSV file",
+		}, {
+			Item:     archiveFormatMarkdown,
+			HelpText: "Archive the posts in a Markdown file",
+		},
+	})
+
 	if skipConfirmationDialogEnabled {
 		cmd.AddNamedStaticListArgument(argNoConfirm, "Do not show confirmation dialog", false, []model.AutocompleteListItem{
 			{
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestPostFiltersMatches(t *testing.T) {
+	post := &model.Post{UserId: "user1", Message: "Build FAILED on main"}
+
+	for name, tc := range map[string]struct {
+		filters  postFilters
+		expected bool
+	}{
+		"no filters":           {filters: postFilters{}, expected: true},
+		"user":                 {filters: postFilters{UserIDs: []string{"user1"}}, expected: true},
+		"other user":           {filters: postFilters{UserIDs: []string{"user2"}}, expected: false},
+		"excluded user":        {filters: postFilters{NotUserIDs: []string{"user1"}}, expected: false},
+		"contains":             {filters: postFilters{Contains: "build failed"}, expected: true},
+		"does not contain":     {filters: postFilters{Contains: "build passed"}, expected: false},
+		"match":                {filters: postFilters{Match: `^Build (FAILED|ERRORED)`}, expected: true},
+		"no match":             {filters: postFilters{Match: `^build failed`}, expected: false},
+		"case insensitive":     {filters: postFilters{Match: `(?i)^build failed`}, expected: true},
+		"invalid regex":        {filters: postFilters{Match: `(`}, expected: false},
+		"match and other user": {filters: postFilters{Match: `FAILED`, UserIDs: []string{"user2"}}, expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if matches := tc.filters.matches(post); matches != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, matches)
+			}
+		})
+	}
+}
+
+func TestMatchesAnyType(t *testing.T) {
+	userPost := &model.Post{Message: "hello"}
+	joinPost := &model.Post{Type: model.PostTypeJoinChannel}
+	headerPost := &model.Post{Type: model.PostTypeHeaderChange}
+	webhookPost := &model.Post{Message: "build failed"}
+	webhookPost.AddProp(model.PostPropsFromWebhook, "true")
+	botPost := &model.Post{Message: "reminder"}
+	botPost.AddProp(model.PostPropsFromBot, "true")
+
+	for name, tc := range map[string]struct {
+		post     *model.Post
+		types    []string
+		expected bool
+	}{
+		"user post as user":         {post: userPost, types: []string{postTypeUser}, expected: true},
+		"user post as system":       {post: userPost, types: []string{postTypeSystem}, expected: false},
+		"join as join-leave":        {post: joinPost, types: []string{postTypeJoinLeave}, expected: true},
+		"join as system":            {post: joinPost, types: []string{postTypeSystem}, expected: true},
+		"header change as system":   {post: headerPost, types: []string{postTypeSystem}, expected: true},
+		"header change as join":     {post: headerPost, types: []string{postTypeJoinLeave}, expected: false},
+		"webhook as webhook":        {post: webhookPost, types: []string{postTypeWebhook}, expected: true},
+		"webhook as user":           {post: webhookPost, types: []string{postTypeUser}, expected: false},
+		"bot as bot":                {post: botPost, types: []string{postTypeBot}, expected: true},
+		"bot as webhook or user":    {post: botPost, types: []string{postTypeWebhook, postTypeUser}, expected: false},
+		"bot as webhook or bot":     {post: botPost, types: []string{postTypeWebhook, postTypeBot}, expected: true},
+		"header change as user":     {post: headerPost, types: []string{postTypeUser}, expected: false},
+		"join as bot or join-leave": {post: joinPost, types: []string{postTypeBot, postTypeJoinLeave}, expected: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if matches := matchesAnyType(tc.post, tc.types); matches != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, matches)
+			}
+		})
+	}
+}
+
+func TestCompileMatch(t *testing.T) {
+	long := make([]byte, maxMatchLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+
+	if _, err := compileMatch(string(long)); err == nil {
+		t.Error("expected an error for a too long regular expression")
+	}
+
+	if _, err := compileMatch("build (failed"); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+
+	if _, err := compileMatch("build (failed|errored)"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseDialogState(t *testing.T) {
+	for name, tc := range map[string]struct {
+		state    string
+		expected *dialogState
+	}{
+		"value only":             {state: `{"value":"10"}`, expected: &dialogState{Value: "10"}},
+		"archive format":         {state: `{"value":"10","archive_format":"csv"}`, expected: &dialogState{Value: "10", ArchiveFormat: "csv"}},
+		"unknown archive format": {state: `{"value":"10","archive_format":"exe"}`, expected: nil},
+		"invalid JSON":           {state: `{"value":`, expected: nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			state, err := parseDialogState(tc.state)
+			if tc.expected == nil {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", state)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if state.Value != tc.expected.Value || state.ArchiveFormat != tc.expected.ArchiveFormat {
+				t.Errorf("expected %+v, got %+v", tc.expected, state)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cronMacros are the shortcuts accepted in place of a cron expression
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// cronSchedule is a parsed cron expression, with the allowed values of each field
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+
+	// Like in cron, if both the day of month and the day of week are restricted,
+	// a time matches if any of them matches
+	daysOfMonthRestricted bool
+	daysOfWeekRestricted  bool
+}
+
+// parseCron parses a standard 5-field cron expression "minute hour day-of-month month day-of-week",
+// supporting `*`, ranges `1-5`, steps `*/15` and lists `1,15`, or one of the @hourly, @daily, @weekly
+// and @monthly macros
+func parseCron(expr string) (*cronSchedule, error) {
+	if macro, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("The cron expression `%s` should have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+
+	schedule := &cronSchedule{}
+	var err error
+
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, errors.Wrap(err, "invalid minute")
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, errors.Wrap(err, "invalid hour")
+	}
+	if schedule.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, errors.Wrap(err, "invalid day of month")
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, errors.Wrap(err, "invalid month")
+	}
+	if schedule.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, errors.Wrap(err, "invalid day of week")
+	}
+
+	// 7 is Sunday too
+	if schedule.daysOfWeek[7] {
+		schedule.daysOfWeek[0] = true
+	}
+
+	schedule.daysOfMonthRestricted = fields[2] != "*"
+	schedule.daysOfWeekRestricted = fields[4] != "*"
+
+	return schedule, nil
+}
+
+// parseCronField returns the values allowed by a field of a cron expression
+func parseCronField(field string, minValue int, maxValue int) (map[int]bool, error) {
+	values := map[int]bool{}
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, errors.Errorf("invalid step in `%s`", part)
+			}
+		}
+
+		start, end := minValue, maxValue
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, errors.Errorf("invalid value `%s`", part)
+			}
+
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, errors.Errorf("invalid value `%s`", part)
+				}
+			} else if step > 1 {
+				// "5/15" means from 5 to the maximum, every 15
+				end = maxValue
+			}
+		}
+
+		if start < minValue || end > maxValue || start > end {
+			return nil, errors.Errorf("`%s` is out of range %d-%d", part, minValue, maxValue)
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+// matches tells if the schedule should run at the minute of t
+func (schedule *cronSchedule) matches(t time.Time) bool {
+	if !schedule.minutes[t.Minute()] || !schedule.hours[t.Hour()] || !schedule.months[int(t.Month())] {
+		return false
+	}
+
+	dayOfMonth := schedule.daysOfMonth[t.Day()]
+	dayOfWeek := schedule.daysOfWeek[int(t.Weekday())]
+
+	if schedule.daysOfMonthRestricted && schedule.daysOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+
+	return dayOfMonth && dayOfWeek
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expr          string
+		expectedError bool
+	}{
+		"every minute":       {expr: "* * * * *"},
+		"nightly":            {expr: "0 3 * * *"},
+		"steps and ranges":   {expr: "*/15 9-17 * * 1-5"},
+		"lists":              {expr: "0,30 8,20 1,15 * *"},
+		"sunday as 7":        {expr: "0 0 * * 7"},
+		"macro":              {expr: "@daily"},
+		"missing field":      {expr: "0 3 * *", expectedError: true},
+		"too many fields":    {expr: "0 3 * * * *", expectedError: true},
+		"minute too high":    {expr: "60 * * * *", expectedError: true},
+		"day of month zero":  {expr: "0 0 0 * *", expectedError: true},
+		"reversed range":     {expr: "0 17-9 * * *", expectedError: true},
+		"invalid step":       {expr: "*/0 * * * *", expectedError: true},
+		"not a number":       {expr: "a * * * *", expectedError: true},
+		"unknown macro":      {expr: "@yearly", expectedError: true},
+		"negative in a list": {expr: "0,-1 * * * *", expectedError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseCron(tc.expr)
+			if tc.expectedError && err == nil {
+				t.Fatalf("expected an error for `%s`", tc.expr)
+			}
+			if !tc.expectedError && err != nil {
+				t.Fatalf("unexpected error for `%s`: %v", tc.expr, err)
+			}
+		})
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	// Sunday 18 October 2026
+	sunday := time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)
+	// Monday 19 October 2026
+	monday := time.Date(2026, 10, 19, 9, 15, 0, 0, time.UTC)
+
+	for name, tc := range map[string]struct {
+		expr     string
+		time     time.Time
+		expected bool
+	}{
+		"every minute":                   {expr: "* * * * *", time: monday, expected: true},
+		"nightly at 3":                   {expr: "0 3 * * *", time: sunday, expected: true},
+		"nightly at 3, other hour":       {expr: "0 3 * * *", time: monday, expected: false},
+		"every 15 minutes":               {expr: "*/15 * * * *", time: monday, expected: true},
+		"working days":                   {expr: "15 9 * * 1-5", time: monday, expected: true},
+		"working days, on sunday":        {expr: "0 3 * * 1-5", time: sunday, expected: false},
+		"sunday as 7":                    {expr: "0 3 * * 7", time: sunday, expected: true},
+		"day of month or day of week":    {expr: "0 3 1 * 0", time: sunday, expected: true},
+		"day of month and any weekday":   {expr: "0 3 1 * *", time: sunday, expected: false},
+		"month":                          {expr: "0 3 * 10 *", time: sunday, expected: true},
+		"other month":                    {expr: "0 3 * 11 *", time: sunday, expected: false},
+		"weekly macro":                   {expr: "@weekly", time: sunday.Add(-3 * time.Hour), expected: true},
+		"range with step":                {expr: "0-30/15 9 * * *", time: monday, expected: true},
+		"range with step, not in range":  {expr: "30-59/15 9 * * *", time: monday, expected: false},
+		"start with step":                {expr: "5/10 9 * * *", time: monday, expected: true},
+		"start with step, not in series": {expr: "5/20 9 * * *", time: monday, expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			schedule, err := parseCron(tc.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if matches := schedule.matches(tc.time); matches != tc.expected {
+				t.Errorf("expected %v, got %v for `%s` at %v", tc.expected, matches, tc.expr, tc.time)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// dryRunNumExcerpts is the number of posts previewed in a dry run report
+const dryRunNumExcerpts = 5
+
+// getDryRunReport selects the posts of postList exactly like deletePosts would, and describes them
+// without deleting anything
+func (p *Plugin) getDryRunReport(postList *model.PostList, options *deletionOptions) string {
+	return "#### Dry run: no post has been deleted\n" + p.getSelectionReport(postList, options)
+}
+
+// getSelectionReport describes the posts of postList that would be deleted:
+// their number, time range and authors, and the first ones
+func (p *Plugin) getSelectionReport(postList *model.PostList, options *deletionOptions) string {
+	result := new(deletePostResult)
+	selected := p.selectPostsToDelete(postList, options, result)
+	p.fillMissingReplyCounts(selected)
+	keptRoots := getKeptRoots(selected)
+	numPosts := countPostsToDelete(selected)
+
+	// Like in deletePosts, the root posts kept for the other replies of their thread are left out,
+	// and the replies of the deleted root posts are deleted along with them
+	toDelete := []*model.Post{}
+	numPostsByAuthor := map[string]int{}
+	numThreadReplies := 0
+	for _, postID := range selected.Order {
+		post := selected.Posts[postID]
+		if keptRoots[post.Id] {
+			result.keptRootPosts = append(result.keptRootPosts, post)
+			continue
+		}
+
+		toDelete = append(toDelete, post)
+		numPostsByAuthor[post.UserId]++
+		if _, ok := selected.Posts[post.RootId]; post.RootId != "" && ok && !keptRoots[post.RootId] {
+			numThreadReplies++
+		}
+	}
+
+	report := ""
+	if len(toDelete) == 0 {
+		return "No post matches your criteria.\n" + result.skippedString()
+	}
+
+	location := p.getUserLocation(options.userID)
+	oldestPost := toDelete[len(toDelete)-1]
+	newestPost := toDelete[0]
+
+	report += fmt.Sprintf(
+		"%d post%s would be deleted, posted between %s and %s.\n",
+		numPosts, getPluralChar(numPosts),
+		formatTime(oldestPost.CreateAt, location), formatTime(newestPost.CreateAt, location),
+	)
+
+	if numThreadReplies > 0 {
+		replies := "reply"
+		if numThreadReplies > 1 {
+			replies = "replies"
+		}
+		report += fmt.Sprintf("This includes %d thread %s deleted along with the root post.\n", numThreadReplies, replies)
+	}
+
+	report += result.skippedString()
+
+	authorIDs := make([]string, 0, len(numPostsByAuthor))
+	for authorID := range numPostsByAuthor {
+		authorIDs = append(authorIDs, authorID)
+	}
+	sort.Slice(authorIDs, func(i, j int) bool {
+		return numPostsByAuthor[authorIDs[i]] > numPostsByAuthor[authorIDs[j]]
+	})
+
+	report += "\n| Author | Posts |\n|:--|--:|\n"
+	for _, authorID := range authorIDs {
+		report += fmt.Sprintf("| %s | %d |\n", p.getUserMention(authorID), numPostsByAuthor[authorID])
+	}
+
+	report += "\n**First posts:**\n"
+	for i := len(toDelete) - 1; i >= 0 && i >= len(toDelete)-dryRunNumExcerpts; i-- {
+		post := toDelete[i]
+		report += fmt.Sprintf(" * %s — %s\n", formatTime(post.CreateAt, location), p.getPostPreview(post))
+	}
+
+	return strings.TrimSuffix(report, "\n")
+}
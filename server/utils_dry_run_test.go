@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSelectionReport(t *testing.T) {
+	// From the most recent post, like the channel history
+	postList := model.NewPostList()
+	for _, post := range []*model.Post{
+		{Id: "otherReply", UserId: "other", RootId: "keptRoot", CreateAt: 5},
+		{Id: "userReply", UserId: "user", RootId: "keptRoot", CreateAt: 4},
+		{Id: "keptRoot", UserId: "user", ReplyCount: 2, CreateAt: 3},
+		{Id: "reply", UserId: "user", RootId: "root", CreateAt: 2},
+		{Id: "root", UserId: "user", ReplyCount: 1, CreateAt: 1},
+	} {
+		postList.AddPost(post)
+		postList.AddOrder(post.Id)
+	}
+
+	api := &plugintest.API{}
+	api.On("KVGet", getProtectedAuthorsKey("channel")).Return(nil, nil)
+	api.On("GetUser", "user").Return(&model.User{Id: "user", Username: "user"}, nil)
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.client = pluginapi.NewClient(api, nil)
+
+	options := &deletionOptions{channelID: "channel", userID: "user", filters: postFilters{UserIDs: []string{"user"}}}
+	report := p.getSelectionReport(postList, options)
+
+	// The root post whose thread has a reply of another user is kept,
+	// and the whole thread of the other root post is deleted
+	lines := strings.Split(report, "\n")
+	assert.True(t, strings.HasPrefix(lines[0], "3 posts would be deleted"), lines[0])
+	assert.Equal(t, "This includes 1 thread reply deleted along with the root post.", lines[1])
+	assert.Equal(t, "1 root post not deleted to keep the other replies of their thread.", lines[2])
+	assert.Contains(t, report, "| @user | 3 |")
+	api.AssertNotCalled(t, "GetPostThread", mock.Anything)
+}
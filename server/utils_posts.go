@@ -2,12 +2,25 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// postsPerPage is the number of posts fetched at once when walking the channel history
+	postsPerPage = 200
+
+	// previewLength is the maximum number of characters of a message shown in a post preview
+	previewLength = 80
 )
 
 // getRelevantPostList filters out the unwanted posts and return the postList with the relevant posts
-// because model.PostList.Posts contains the searched posts AND all the posts of all the linked threads
+// because model.PostList.Posts contains the searched posts AND all the posts of all the linked threads.
+// The replies of the linked threads are counted in their root post before being discarded
 func getRelevantPostList(postList *model.PostList) *model.PostList {
 	relevantPosts := make(map[string]*model.Post, len(postList.Order))
 
@@ -15,15 +28,259 @@ func getRelevantPostList(postList *model.PostList) *model.PostList {
 		relevantPosts[postID] = postList.Posts[postID]
 	}
 
+	replyCounts := map[string]int64{}
+	for _, post := range postList.Posts {
+		if post.RootId != "" && post.DeleteAt == 0 {
+			replyCounts[post.RootId]++
+		}
+	}
+
+	for _, post := range relevantPosts {
+		if post.RootId == "" {
+			post.ReplyCount = max(post.ReplyCount, replyCounts[post.Id])
+		}
+	}
+
 	postList.Posts = relevantPosts
 	return postList
 }
 
+// walkChannelPosts calls fn on every post of the channel, from the most recent to the oldest one,
+// until fn returns false or the beginning of the channel is reached.
+// The channel history is paged with GetPostsBefore, so posts created meanwhile don't shift the pages.
+// Each post is given once, even if it appears in several pages, like the roots of the threads spanning pages.
+// The replies are always more recent than their root, so the ReplyCount of a root post is set
+// from the replies met before it if the server did not provide it
+func (p *Plugin) walkChannelPosts(channelID string, fn func(post *model.Post) bool) *model.AppError {
+	return p.walkChannelPostsBefore(channelID, "", fn)
+}
+
+// walkChannelPostsBefore is like walkChannelPosts, but starts from the post preceding beforePostID, if not empty
+func (p *Plugin) walkChannelPostsBefore(channelID string, beforePostID string, fn func(post *model.Post) bool) *model.AppError {
+	var postList *model.PostList
+	var appErr *model.AppError
+	if beforePostID == "" {
+		postList, appErr = p.API.GetPostsForChannel(channelID, 0, postsPerPage)
+	} else {
+		postList, appErr = p.API.GetPostsBefore(channelID, beforePostID, 0, postsPerPage)
+	}
+
+	seen := map[string]bool{}
+	replyCounts := map[string]int64{}
+
+	for {
+		if appErr != nil {
+			return appErr
+		}
+
+		numNewPosts := 0
+		for _, postID := range postList.Order {
+			if seen[postID] {
+				continue
+			}
+			seen[postID] = true
+			numNewPosts++
+
+			post := postList.Posts[postID]
+			if post.RootId != "" {
+				replyCounts[post.RootId]++
+			} else {
+				post.ReplyCount = max(post.ReplyCount, replyCounts[post.Id])
+			}
+
+			if !fn(post) {
+				return nil
+			}
+		}
+
+		// Stop at the beginning of the channel, or if the history does not move forward anymore
+		if len(postList.Order) < postsPerPage || numNewPosts == 0 {
+			return nil
+		}
+
+		oldestPostID := postList.Order[len(postList.Order)-1]
+		postList, appErr = p.API.GetPostsBefore(channelID, oldestPostID, 0, postsPerPage)
+	}
+}
+
+// getLastPosts returns the last numPost posts of the channel, or all its posts if there are fewer
+func (p *Plugin) getLastPosts(channelID string, numPost int) (*model.PostList, *model.AppError) {
+	result := model.NewPostList()
+
+	appErr := p.walkChannelPosts(channelID, func(post *model.Post) bool {
+		result.AddPost(post)
+		result.AddOrder(post.Id)
+
+		return len(result.Order) < numPost
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return result, nil
+}
+
+// getPostsSince returns the posts of the channel created at or after since (in milliseconds)
+func (p *Plugin) getPostsSince(channelID string, since int64) (*model.PostList, *model.AppError) {
+	return p.getPostsBetween(channelID, since, math.MaxInt64)
+}
+
+// getPostsBetween returns the posts of the channel created between start and end (in milliseconds, inclusive).
+// If end is in the past, the history is walked from the oldest post created after end,
+// rather than from the most recent post of the channel
+func (p *Plugin) getPostsBetween(channelID string, start int64, end int64) (*model.PostList, *model.AppError) {
+	beforePostID := ""
+	if end < model.GetMillis() {
+		postsAfter, appErr := p.API.GetPostsSince(channelID, end)
+		if appErr != nil {
+			return nil, appErr
+		}
+		beforePostID = getOldestPostAfter(postsAfter, end)
+	}
+
+	result := model.NewPostList()
+
+	appErr := p.walkChannelPostsBefore(channelID, beforePostID, func(post *model.Post) bool {
+		if post.CreateAt < start {
+			return false
+		}
+
+		if post.CreateAt <= end {
+			result.AddPost(post)
+			result.AddOrder(post.Id)
+		}
+
+		return true
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return result, nil
+}
+
+// getOldestPostAfter returns the ID of the oldest post of postList created after the time (in milliseconds),
+// or an empty string if there is none. The deleted posts and the posts only updated after the time are ignored
+func getOldestPostAfter(postList *model.PostList, after int64) string {
+	var oldest *model.Post
+	for _, post := range postList.Posts {
+		if post.CreateAt <= after || post.DeleteAt != 0 {
+			continue
+		}
+
+		if oldest == nil || post.CreateAt < oldest.CreateAt {
+			oldest = post
+		}
+	}
+
+	if oldest == nil {
+		return ""
+	}
+
+	return oldest.Id
+}
+
+// getPostPreview returns a one-line preview of the post, prefixed by its author
+func (p *Plugin) getPostPreview(post *model.Post) string {
+	message := strings.Join(strings.Fields(post.Message), " ")
+	if runes := []rune(message); len(runes) > previewLength {
+		message = string(runes[:previewLength]) + "…"
+	}
+
+	if message == "" {
+		message = "_(no message)_"
+	}
+
+	return fmt.Sprintf("%s: %s", p.getUserMention(post.UserId), message)
+}
+
+// recreatePosts creates the given posts again in the channel, from the oldest to the most recent one.
+// A reply is attached to its recreated root post, or to its original root post if it still exists in the channel,
+// otherwise it becomes a root post itself. transform, if not nil, may modify each post before its creation,
+// knowing the original post. The post is not created if it returns an error.
+// Returns the IDs of the posts created, from the oldest one, and the number of posts that could not be created
+func (p *Plugin) recreatePosts(
+	posts []*model.Post,
+	channelID string,
+	transform func(original *model.Post, post *model.Post) error,
+) ([]string, int) {
+	sortedPosts := make([]*model.Post, len(posts))
+	copy(sortedPosts, posts)
+	sort.SliceStable(sortedPosts, func(i, j int) bool {
+		return sortedPosts[i].CreateAt < sortedPosts[j].CreateAt
+	})
+
+	newPostIDs := make(map[string]string, len(sortedPosts))
+	createdPostIDs := make([]string, 0, len(sortedPosts))
+	numFailed := 0
+
+	for _, original := range sortedPosts {
+		post := original.Clone()
+		post.Id = ""
+		post.ChannelId = channelID
+		post.UpdateAt = 0
+		post.DeleteAt = 0
+		post.ReplyCount = 0
+		post.Metadata = nil
+
+		if post.RootId != "" {
+			if newRootID, ok := newPostIDs[post.RootId]; ok {
+				post.RootId = newRootID
+			} else if root, appErr := p.API.GetPost(post.RootId); appErr != nil || root.ChannelId != channelID {
+				post.RootId = ""
+			}
+		}
+
+		if transform != nil {
+			if err := transform(original, post); err != nil {
+				numFailed++
+				p.API.LogError("Unable to prepare the post to recreate", "PostID", original.Id, "err", err)
+				continue
+			}
+		}
+
+		createdPost, appErr := p.API.CreatePost(post)
+		if appErr != nil {
+			numFailed++
+			p.API.LogError("Unable to recreate post", "PostID", original.Id, "appErr", appErr)
+			continue
+		}
+
+		newPostIDs[original.Id] = createdPost.Id
+		createdPostIDs = append(createdPostIDs, createdPost.Id)
+	}
+
+	return createdPostIDs, numFailed
+}
+
 type deletePostResult struct {
 	numPostsDeleted    int
 	technicalErrors    int
 	notPermittedErrors int
 	pinnedPostErrors   int
+
+	// protectedPostErrors counts the posts not deleted because their author is protected
+	protectedPostErrors int
+
+	// keptRootPosts contains the selected root posts not deleted to keep the other replies of their thread
+	keptRootPosts []*model.Post
+
+	// Breakdown of numPostsDeleted
+	numRootPostsDeleted      int
+	numRepliesDeleted        int
+	numCascadeRepliesDeleted int
+
+	// deletedPosts contains the snapshot of the deleted posts, if they can be restored
+	deletedPosts []*model.Post
+
+	// failedPosts contains the posts that could not be deleted because of a technical error
+	failedPosts []*model.Post
+}
+
+// addTechnicalError counts the post as not deleted because of a technical error
+func (result *deletePostResult) addTechnicalError(post *model.Post) {
+	result.technicalErrors++
+	result.failedPosts = append(result.failedPosts, post)
 }
 
 func (result *deletePostResult) String() (strResponse string) {
@@ -34,6 +291,56 @@ func (result *deletePostResult) String() (strResponse string) {
 		)
 	}
 
+	strResponse += result.skippedString()
+
+	if result.numPostsDeleted > 0 {
+		strResponse += fmt.Sprintf(
+			"Successfully deleted %d post%s.",
+			result.numPostsDeleted, getPluralChar(result.numPostsDeleted))
+
+		strResponse += result.breakdownString()
+	}
+
+	if strResponse == "" {
+		strResponse = "There are no posts in this channel."
+	}
+
+	return strResponse
+}
+
+// breakdownString details the kinds of deleted posts, when there are several of them
+func (result *deletePostResult) breakdownString() (strResponse string) {
+	isMixed := result.numRootPostsDeleted > 0 && result.numRepliesDeleted > 0
+	if !isMixed && result.numCascadeRepliesDeleted == 0 {
+		return ""
+	}
+
+	if result.numRootPostsDeleted > 0 {
+		strResponse += fmt.Sprintf(
+			"\n * %d root post%s",
+			result.numRootPostsDeleted, getPluralChar(result.numRootPostsDeleted),
+		)
+	}
+
+	if result.numRepliesDeleted > 0 {
+		strResponse += fmt.Sprintf(
+			"\n * %d repl%s",
+			result.numRepliesDeleted, getPluralSuffix(result.numRepliesDeleted, "y", "ies"),
+		)
+	}
+
+	if result.numCascadeRepliesDeleted > 0 {
+		strResponse += fmt.Sprintf(
+			"\n * %d repl%s deleted along with their thread",
+			result.numCascadeRepliesDeleted, getPluralSuffix(result.numCascadeRepliesDeleted, "y", "ies"),
+		)
+	}
+
+	return strResponse
+}
+
+// skippedString describes the posts that were left out of the deletion
+func (result *deletePostResult) skippedString() (strResponse string) {
 	if result.pinnedPostErrors > 0 {
 		strResponse += fmt.Sprintf(
 			"%d post%s not deleted because they are pinned to the channel.\n",
@@ -41,6 +348,20 @@ func (result *deletePostResult) String() (strResponse string) {
 		)
 	}
 
+	if result.protectedPostErrors > 0 {
+		strResponse += fmt.Sprintf(
+			"%d post%s not deleted because their author is protected.\n",
+			result.protectedPostErrors, getPluralChar(result.protectedPostErrors),
+		)
+	}
+
+	if len(result.keptRootPosts) > 0 {
+		strResponse += fmt.Sprintf(
+			"%d root post%s not deleted to keep the other replies of their thread.\n",
+			len(result.keptRootPosts), getPluralChar(len(result.keptRootPosts)),
+		)
+	}
+
 	if result.notPermittedErrors > 0 {
 		if result.numPostsDeleted == 0 {
 			strResponse += "Sorry, you are only allowed to delete your own posts\n"
@@ -52,29 +373,34 @@ func (result *deletePostResult) String() (strResponse string) {
 		}
 	}
 
-	if result.numPostsDeleted > 0 {
-		strResponse += fmt.Sprintf(
-			"Successfully deleted %d post%s.",
-			result.numPostsDeleted, getPluralChar(result.numPostsDeleted))
-	}
-
-	if strResponse == "" {
-		strResponse = "There are no posts in this channel."
-	}
-
 	return strResponse
 }
 
-// deletePosts deletes all the posts in postList that matches the criteria of options
-// This assumes the user has the rights to delete posts
-// ! This check has to be made before!
-func (p *Plugin) deletePosts(postList *model.PostList, options *deletionOptions) *deletePostResult {
-	p.API.LogInfo("Batch deleting these posts", "postIds", postList.Order)
-	result := new(deletePostResult)
+// selectPostsToDelete returns the posts of postList that match the criteria of options,
+// except the ones of the protected authors of the channel
+func (p *Plugin) selectPostsToDelete(postList *model.PostList, options *deletionOptions, result *deletePostResult) *model.PostList {
+	isAuthorProtected := p.getAuthorProtection(options.channelID)
+	return filterPostsToDelete(postList, options, isAuthorProtected, result)
+}
+
+// filterPostsToDelete returns the posts of postList that match the criteria of options.
+// The posts left out because the user is not permitted to delete them, because they are pinned
+// or because their author is protected are counted in result
+func filterPostsToDelete(
+	postList *model.PostList,
+	options *deletionOptions,
+	isAuthorProtected func(userID string) bool,
+	result *deletePostResult,
+) *model.PostList {
+	selected := model.NewPostList()
 
 	for _, postID := range postList.Order {
 		post := postList.Posts[postID]
 
+		if !options.filters.matches(post) {
+			continue // process next post
+		}
+
 		if !options.permDeleteOthersPosts && post.UserId != options.userID {
 			result.notPermittedErrors++
 			continue // process next post
@@ -85,27 +411,228 @@ func (p *Plugin) deletePosts(postList *model.PostList, options *deletionOptions)
 			continue // process next post
 		}
 
+		if isAuthorProtected(post.UserId) {
+			result.protectedPostErrors++
+			continue // process next post
+		}
+
+		selected.AddPost(post)
+		selected.AddOrder(postID)
+	}
+
+	return selected
+}
+
+// deletePosts deletes all the posts in postList that matches the criteria of options
+// This assumes the user has the rights to delete posts
+// ! This check has to be made before!
+// onProgress, if not nil, is called before each post and once all the posts are processed.
+// The deletion stops if it returns false
+func (p *Plugin) deletePosts(
+	postList *model.PostList,
+	options *deletionOptions,
+	onProgress func(processed int, total int, result *deletePostResult) bool,
+) *deletePostResult {
+	result := new(deletePostResult)
+	postListToDelete := p.selectPostsToDelete(postList, options, result)
+	p.API.LogInfo("Batch deleting these posts", "postIds", postListToDelete.Order)
+
+	p.fillMissingReplyCounts(postListToDelete)
+	keptRoots := getKeptRoots(postListToDelete)
+
+	// The selected replies whose root is deleted too, waiting for the deletion of their root.
+	// The posts are ordered from the most recent one, so the replies are processed before their root
+	pendingReplies := map[string][]*model.Post{}
+
+	for i, postID := range postListToDelete.Order {
+		if onProgress != nil && !onProgress(i, len(postListToDelete.Order), result) {
+			return result
+		}
+
+		post := postListToDelete.Posts[postID]
+
 		if post.RootId != "" {
 			// The post is in a thread: skip it if the root will be also deleted,
 			// because deleting a root post automatically delete the whole thread
-			if _, ok := postList.Posts[post.RootId]; ok {
-				result.numPostsDeleted++
+			if _, ok := postListToDelete.Posts[post.RootId]; ok && !keptRoots[post.RootId] {
+				pendingReplies[post.RootId] = append(pendingReplies[post.RootId], post)
 				continue // process next post
 			}
 		}
 
-		if appErr := p.API.DeletePost(post.Id); appErr != nil {
-			result.technicalErrors++
-			p.API.LogError("Unable to delete post", "PostID", post.Id, "appErr", appErr)
+		if keptRoots[post.Id] {
+			// Deleting the root post would delete the replies of its thread that are not selected
+			result.keptRootPosts = append(result.keptRootPosts, post)
 			continue // process next post
 		}
 
-		// FIXME Count is not accurate if we delete posts having children, but if we do not delete the children before
-		// for example when using filters.
-		// We can't use post.ReplyCount as a reference because it's not populated when using
-		// the API
-		result.numPostsDeleted++
+		if !p.deletePost(post, pendingReplies[post.Id], options, result) && post.RootId == "" {
+			// The thread is still there, delete the selected replies one by one
+			for _, reply := range pendingReplies[post.Id] {
+				p.deletePost(reply, nil, options, result)
+			}
+		}
+		delete(pendingReplies, post.Id)
+	}
+
+	if onProgress != nil {
+		onProgress(len(postListToDelete.Order), len(postListToDelete.Order), result)
 	}
 
 	return result
 }
+
+// fillMissingReplyCounts counts the replies of the selected root posts from their thread,
+// when the reply count is missing as more replies of the thread have been selected
+func (p *Plugin) fillMissingReplyCounts(selected *model.PostList) {
+	for root, numSelectedReplies := range countSelectedReplies(selected) {
+		if int(root.ReplyCount) < numSelectedReplies {
+			root.ReplyCount = int64(p.countThreadReplies(root.Id))
+		}
+	}
+}
+
+// countSelectedReplies returns the number of selected replies of each selected root post
+func countSelectedReplies(selected *model.PostList) map[*model.Post]int {
+	numSelectedReplies := map[*model.Post]int{}
+	for _, postID := range selected.Order {
+		post := selected.Posts[postID]
+		if root, ok := selected.Posts[post.RootId]; ok && post.RootId != "" {
+			numSelectedReplies[root]++
+		}
+	}
+
+	return numSelectedReplies
+}
+
+// getKeptRoots returns the IDs of the selected root posts whose thread has replies that are not selected,
+// because they don't match the criteria, are pinned, protected or not permitted. These root posts are kept,
+// as deleting them would delete the whole thread, and only their selected replies are deleted
+func getKeptRoots(selected *model.PostList) map[string]bool {
+	numSelectedReplies := countSelectedReplies(selected)
+
+	keptRoots := map[string]bool{}
+	for _, postID := range selected.Order {
+		post := selected.Posts[postID]
+		if post.RootId == "" && int(post.ReplyCount) > numSelectedReplies[post] {
+			keptRoots[post.Id] = true
+		}
+	}
+
+	return keptRoots
+}
+
+// deletePost deletes the post, keeping a snapshot if it can be restored, and counts it in result.
+// replies are the fetched replies of a root post, deleted along with it.
+// Returns false if the post could not be deleted
+func (p *Plugin) deletePost(post *model.Post, replies []*model.Post, options *deletionOptions, result *deletePostResult) bool {
+	var snapshot []*model.Post
+	if p.getUndoGracePeriod() > 0 && !options.optNoUndo {
+		var err error
+		if snapshot, err = p.getPostSnapshot(post, replies); err != nil {
+			result.addTechnicalError(post)
+			p.API.LogError("Unable to keep a snapshot of the post, not deleting it", "PostID", post.Id, "err", err)
+			return false
+		}
+	}
+
+	// The replies of a root post are deleted along with it, so they are counted beforehand,
+	// with the thread of the snapshot if there is one
+	numReplies := 0
+	if post.RootId == "" {
+		numReplies = int(post.ReplyCount)
+		if snapshot != nil {
+			numReplies = len(snapshot) - 1
+		}
+	}
+
+	if appErr := p.API.DeletePost(post.Id); appErr != nil {
+		result.addTechnicalError(post)
+		p.API.LogError("Unable to delete post", "PostID", post.Id, "appErr", appErr)
+		return false
+	}
+
+	result.deletedPosts = append(result.deletedPosts, snapshot...)
+
+	if post.RootId == "" {
+		result.numRootPostsDeleted++
+		result.numCascadeRepliesDeleted += numReplies
+	} else {
+		result.numRepliesDeleted++
+	}
+	result.numPostsDeleted += 1 + numReplies
+
+	return true
+}
+
+// getPostWithThread returns the post and, if it is a thread root, all its replies.
+// The replies are taken from the ones already fetched, and the thread is only fetched if some of them are missing
+func (p *Plugin) getPostWithThread(post *model.Post, replies []*model.Post) ([]*model.Post, error) {
+	if post.RootId != "" {
+		return []*model.Post{post}, nil
+	}
+
+	if int(post.ReplyCount) > len(replies) {
+		thread, appErr := p.API.GetPostThread(post.Id)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to get thread")
+		}
+
+		replies = []*model.Post{}
+		for _, threadPost := range thread.Posts {
+			if threadPost.Id != post.Id {
+				replies = append(replies, threadPost)
+			}
+		}
+	}
+
+	return append([]*model.Post{post}, replies...), nil
+}
+
+// countThreadReplies returns the number of replies of the root post
+func (p *Plugin) countThreadReplies(rootID string) int {
+	thread, appErr := p.API.GetPostThread(rootID)
+	if appErr != nil {
+		p.API.LogWarn("Unable to count the replies of the thread", "PostID", rootID, "appErr", appErr)
+		return 0
+	}
+
+	numReplies := 0
+	for _, threadPost := range thread.Posts {
+		if threadPost.Id != rootID && threadPost.DeleteAt == 0 {
+			numReplies++
+		}
+	}
+
+	return numReplies
+}
+
+// deletePostsAndReport deletes the relevant posts of postList in a background job, which reports the result
+// to the user in an ephemeral post. If requested, the posts are archived before being deleted.
+// In a dry run, it only reports what would be deleted. Above the approval threshold, the deletion waits
+// for the approval of a second user
+func (p *Plugin) deletePostsAndReport(postList *model.PostList, options *deletionOptions) {
+	if options.optDryRun {
+		p.sendEphemeralPost(options.userID, options.channelID, p.getDryRunReport(getRelevantPostList(postList), options))
+		return
+	}
+
+	postList = getRelevantPostList(postList)
+	if p.requiresApproval(postList, options) {
+		// The quota is reserved once the deletion is approved
+		if userErr := p.checkQuotaForPosts(postList, options); userErr != nil {
+			p.sendEphemeralPost(options.userID, options.channelID, userErr.Error())
+			return
+		}
+
+		p.requestApproval(postList, options)
+		return
+	}
+
+	if userErr := p.reserveQuotaForPosts(postList, options); userErr != nil {
+		p.sendEphemeralPost(options.userID, options.channelID, userErr.Error())
+		return
+	}
+
+	p.startDeletionJob(postList, options)
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletePostResultString(t *testing.T) {
+	for name, tc := range map[string]struct {
+		result   deletePostResult
+		expected string
+	}{
+		"nothing": {
+			result:   deletePostResult{},
+			expected: "There are no posts in this channel.",
+		},
+		"only root posts": {
+			result:   deletePostResult{numPostsDeleted: 3, numRootPostsDeleted: 3},
+			expected: "Successfully deleted 3 posts.",
+		},
+		"only replies": {
+			result:   deletePostResult{numPostsDeleted: 2, numRepliesDeleted: 2},
+			expected: "Successfully deleted 2 posts.",
+		},
+		"root posts and replies": {
+			result:   deletePostResult{numPostsDeleted: 3, numRootPostsDeleted: 2, numRepliesDeleted: 1},
+			expected: "Successfully deleted 3 posts.\n * 2 root posts\n * 1 reply",
+		},
+		"cascade replies": {
+			result:   deletePostResult{numPostsDeleted: 6, numRootPostsDeleted: 1, numCascadeRepliesDeleted: 5},
+			expected: "Successfully deleted 6 posts.\n * 1 root post\n * 5 replies deleted along with their thread",
+		},
+		"protected authors": {
+			result:   deletePostResult{numPostsDeleted: 1, numRootPostsDeleted: 1, pinnedPostErrors: 1, protectedPostErrors: 2},
+			expected: "1 post not deleted because they are pinned to the channel.\n2 posts not deleted because their author is protected.\nSuccessfully deleted 1 post.",
+		},
+		"kept root posts": {
+			result:   deletePostResult{numPostsDeleted: 2, numRepliesDeleted: 2, keptRootPosts: []*model.Post{{Id: "root"}}},
+			expected: "1 root post not deleted to keep the other replies of their thread.\nSuccessfully deleted 2 posts.",
+		},
+		"technical error": {
+			result:   deletePostResult{numPostsDeleted: 1, numRootPostsDeleted: 1, technicalErrors: 2},
+			expected: "Because of a technical error, 2 posts could not be deleted.\nSuccessfully deleted 1 post.",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if str := tc.result.String(); str != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, str)
+			}
+		})
+	}
+}
+
+func TestFilterPostsToDelete(t *testing.T) {
+	postList := model.NewPostList()
+	for _, post := range []*model.Post{
+		{Id: "post1", UserId: "user1"},
+		{Id: "post2", UserId: "releasebot"},
+		{Id: "post3", UserId: "user1", IsPinned: true},
+		{Id: "post4", UserId: "releasebot", IsPinned: true},
+	} {
+		postList.AddPost(post)
+		postList.AddOrder(post.Id)
+	}
+
+	isAuthorProtected := func(userID string) bool { return userID == "releasebot" }
+	options := &deletionOptions{userID: "user1", permDeleteOthersPosts: true}
+
+	result := new(deletePostResult)
+	selected := filterPostsToDelete(postList, options, isAuthorProtected, result)
+
+	if len(selected.Order) != 1 || selected.Order[0] != "post1" {
+		t.Errorf("expected only post1 to be selected, got %v", selected.Order)
+	}
+	if result.pinnedPostErrors != 2 {
+		t.Errorf("expected 2 pinned posts, got %d", result.pinnedPostErrors)
+	}
+	if result.protectedPostErrors != 1 {
+		t.Errorf("expected 1 protected post, got %d", result.protectedPostErrors)
+	}
+}
+
+func TestIsAuthorProtected(t *testing.T) {
+	settingsUserIDs := []string{"releasebot"}
+
+	for name, tc := range map[string]struct {
+		userID    string
+		overrides *channelAuthorOverrides
+		expected  bool
+	}{
+		"protected by settings":        {userID: "releasebot", overrides: nil, expected: true},
+		"not protected":                {userID: "user1", overrides: nil, expected: false},
+		"protected in channel":         {userID: "user1", overrides: &channelAuthorOverrides{ProtectedUserIDs: []string{"user1"}}, expected: true},
+		"unprotected in channel":       {userID: "releasebot", overrides: &channelAuthorOverrides{UnprotectedUserIDs: []string{"releasebot"}}, expected: false},
+		"other user unprotected":       {userID: "releasebot", overrides: &channelAuthorOverrides{UnprotectedUserIDs: []string{"user1"}}, expected: true},
+		"unprotected, not in settings": {userID: "user1", overrides: &channelAuthorOverrides{UnprotectedUserIDs: []string{"user1"}}, expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if protected := isAuthorProtected(tc.userID, settingsUserIDs, tc.overrides); protected != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, protected)
+			}
+		})
+	}
+}
+
+func TestDeletePostsCounting(t *testing.T) {
+	// From the most recent post, like the channel history
+	postList := model.NewPostList()
+	for _, post := range []*model.Post{
+		{Id: "explicitReply", RootId: "unselectedRoot"},
+		{Id: "selectedReply", RootId: "rootWithCount"},
+		{Id: "rootWithCount", ReplyCount: 1},
+		{Id: "pendingReply2", RootId: "rootWithoutCount"},
+		{Id: "pendingReply1", RootId: "rootWithoutCount"},
+		{Id: "rootWithoutCount"},
+		{Id: "failedReply", RootId: "failedRoot"},
+		{Id: "failedRoot", ReplyCount: 1},
+	} {
+		postList.AddPost(post)
+		postList.AddOrder(post.Id)
+	}
+
+	// The reply count of the root is missing, it is read from its thread
+	thread := model.NewPostList()
+	for _, post := range []*model.Post{
+		{Id: "rootWithoutCount"},
+		{Id: "pendingReply1", RootId: "rootWithoutCount"},
+		{Id: "pendingReply2", RootId: "rootWithoutCount"},
+	} {
+		thread.AddPost(post)
+		thread.AddOrder(post.Id)
+	}
+
+	api := &plugintest.API{}
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything)
+	api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	api.On("KVGet", getProtectedAuthorsKey("channel")).Return(nil, nil)
+	api.On("GetPostThread", "rootWithoutCount").Return(thread, nil).Once()
+	api.On("DeletePost", "explicitReply").Return(nil).Once()
+	api.On("DeletePost", "rootWithCount").Return(nil).Once()
+	api.On("DeletePost", "rootWithoutCount").Return(nil).Once()
+	api.On("DeletePost", "failedRoot").Return(model.NewAppError("DeletePost", "error", nil, "", http.StatusInternalServerError)).Once()
+	api.On("DeletePost", "failedReply").Return(nil).Once()
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.client = pluginapi.NewClient(api, nil)
+
+	options := &deletionOptions{channelID: "channel", userID: "user", permDeleteOthersPosts: true, optDeletePinnedPosts: true}
+	result := p.deletePosts(postList, options, nil)
+
+	// The replies of the failed root are deleted one by one
+	assert.Equal(t, 1, result.technicalErrors)
+	assert.Equal(t, 2, result.numRootPostsDeleted)
+	assert.Equal(t, 2, result.numRepliesDeleted, "the explicit reply and the reply of the failed root")
+	assert.Equal(t, 3, result.numCascadeRepliesDeleted, "1 counted reply and 2 from the thread")
+	assert.Equal(t, 7, result.numPostsDeleted)
+}
+
+func TestWalkChannelPosts(t *testing.T) {
+	// The channel has 350 posts, from post349 (the most recent one) to post0.
+	// The thread of post100 spans both pages, with a reply in each of them
+	posts := make([]*model.Post, 0, 350)
+	for i := 349; i >= 0; i-- {
+		posts = append(posts, &model.Post{Id: fmt.Sprintf("post%d", i), CreateAt: int64(i)})
+	}
+	threadRoot := posts[349-100]
+	posts[349-300].RootId = threadRoot.Id
+	posts[349-120].RootId = threadRoot.Id
+
+	getPage := func(posts []*model.Post) *model.PostList {
+		page := model.NewPostList()
+		for _, post := range posts {
+			page.AddPost(post)
+			page.AddOrder(post.Id)
+		}
+		return page
+	}
+
+	// The second page starts again with the oldest post of the first one, which must not be given twice
+	firstPage := getPage(posts[:postsPerPage])
+	secondPage := getPage(posts[postsPerPage-1:])
+
+	api := &plugintest.API{}
+	api.On("GetPostsForChannel", "channel", 0, postsPerPage).Return(firstPage, nil).Once()
+	api.On("GetPostsBefore", "channel", "post150", 0, postsPerPage).Return(secondPage, nil).Once()
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	walked := []string{}
+	appErr := p.walkChannelPosts("channel", func(post *model.Post) bool {
+		walked = append(walked, post.Id)
+		return true
+	})
+	require.Nil(t, appErr)
+
+	// The second page is shorter than postsPerPage, so the beginning of the channel is reached
+	require.Len(t, walked, len(posts))
+	for i, post := range posts {
+		assert.Equal(t, post.Id, walked[i])
+	}
+	assert.Equal(t, int64(2), threadRoot.ReplyCount, "the replies of both pages should be counted")
+
+	t.Run("stop early", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("GetPostsForChannel", "channel", 0, postsPerPage).Return(firstPage, nil).Once()
+		defer api.AssertExpectations(t)
+
+		p := &Plugin{}
+		p.SetAPI(api)
+
+		numWalked := 0
+		appErr := p.walkChannelPosts("channel", func(post *model.Post) bool {
+			numWalked++
+			return numWalked < 10
+		})
+		require.Nil(t, appErr)
+		assert.Equal(t, 10, numWalked)
+	})
+}
+
+func TestDeletePostsKeptRoots(t *testing.T) {
+	for name, tc := range map[string]struct {
+		posts     []*model.Post
+		filters   postFilters
+		protected []string
+		deleted   []string
+		kept      []string
+	}{
+		"whole thread selected": {
+			posts: []*model.Post{
+				{Id: "reply", UserId: "other", RootId: "root"},
+				{Id: "root", UserId: "user", ReplyCount: 1},
+			},
+			deleted: []string{"root"},
+		},
+		"reply of another user filtered out": {
+			posts: []*model.Post{
+				{Id: "otherReply", UserId: "other", RootId: "root"},
+				{Id: "userReply", UserId: "user", RootId: "root"},
+				{Id: "root", UserId: "user", ReplyCount: 2},
+			},
+			filters: postFilters{UserIDs: []string{"user"}},
+			deleted: []string{"userReply"},
+			kept:    []string{"root"},
+		},
+		"protected reply": {
+			posts: []*model.Post{
+				{Id: "protectedReply", UserId: "releasebot", RootId: "root"},
+				{Id: "otherReply", UserId: "other", RootId: "root"},
+				{Id: "root", UserId: "user", ReplyCount: 2},
+			},
+			protected: []string{"releasebot"},
+			deleted:   []string{"otherReply"},
+			kept:      []string{"root"},
+		},
+		"replies out of the list": {
+			posts: []*model.Post{
+				{Id: "root", UserId: "user", ReplyCount: 3},
+			},
+			kept: []string{"root"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			postList := model.NewPostList()
+			for _, post := range tc.posts {
+				postList.AddPost(post)
+				postList.AddOrder(post.Id)
+			}
+
+			var overrides []byte
+			if len(tc.protected) > 0 {
+				var err error
+				overrides, err = json.Marshal(&channelAuthorOverrides{ChannelID: "channel", ProtectedUserIDs: tc.protected})
+				require.NoError(t, err)
+			}
+
+			api := &plugintest.API{}
+			api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything)
+			api.On("KVGet", getProtectedAuthorsKey("channel")).Return(overrides, nil)
+			for _, postID := range tc.deleted {
+				api.On("DeletePost", postID).Return(nil).Once()
+			}
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.SetAPI(api)
+			p.client = pluginapi.NewClient(api, nil)
+
+			options := &deletionOptions{channelID: "channel", userID: "user", permDeleteOthersPosts: true, filters: tc.filters}
+			result := p.deletePosts(postList, options, nil)
+
+			keptRoots := []string{}
+			for _, post := range result.keptRootPosts {
+				keptRoots = append(keptRoots, post.Id)
+			}
+			assert.ElementsMatch(t, tc.kept, keptRoots)
+			api.AssertNumberOfCalls(t, "DeletePost", len(tc.deleted))
+		})
+	}
+}
+
+func TestGetOldestPostAfter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		posts    []*model.Post
+		expected string
+	}{
+		"no posts": {posts: []*model.Post{}, expected: ""},
+		"only updated posts": {
+			posts:    []*model.Post{{Id: "edited", CreateAt: 50, UpdateAt: 200}},
+			expected: "",
+		},
+		"oldest created after": {
+			posts: []*model.Post{
+				{Id: "edited", CreateAt: 50, UpdateAt: 200},
+				{Id: "newest", CreateAt: 300},
+				{Id: "oldest", CreateAt: 150},
+			},
+			expected: "oldest",
+		},
+		"deleted post": {
+			posts: []*model.Post{
+				{Id: "deleted", CreateAt: 120, DeleteAt: 400},
+				{Id: "post", CreateAt: 130},
+			},
+			expected: "post",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			postList := model.NewPostList()
+			for _, post := range tc.posts {
+				postList.AddPost(post)
+				postList.AddOrder(post.Id)
+			}
+
+			assert.Equal(t, tc.expected, getOldestPostAfter(postList, 100))
+		})
+	}
+}
+
+func TestGetPostsBetween(t *testing.T) {
+	postsSince := model.NewPostList()
+	for _, post := range []*model.Post{
+		{Id: "after2", CreateAt: 400},
+		{Id: "after1", CreateAt: 300},
+	} {
+		postsSince.AddPost(post)
+		postsSince.AddOrder(post.Id)
+	}
+
+	page := model.NewPostList()
+	for _, post := range []*model.Post{
+		{Id: "inWindow2", CreateAt: 200},
+		{Id: "inWindow1", CreateAt: 100},
+		{Id: "before", CreateAt: 50},
+	} {
+		page.AddPost(post)
+		page.AddOrder(post.Id)
+	}
+
+	// The history is walked from the oldest post after the window, not from the most recent one
+	api := &plugintest.API{}
+	api.On("GetPostsSince", "channel", int64(250)).Return(postsSince, nil).Once()
+	api.On("GetPostsBefore", "channel", "after1", 0, postsPerPage).Return(page, nil).Once()
+	defer api.AssertExpectations(t)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	postList, appErr := p.getPostsBetween("channel", 100, 250)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{"inWindow2", "inWindow1"}, postList.Order)
+	api.AssertNotCalled(t, "GetPostsForChannel", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timeLayout is a layout accepted for dates, along with the unit of its smallest field
+type timeLayout struct {
+	layout string
+	unit   time.Duration
+}
+
+// Layouts accepted for absolute dates, in the timezone of the user
+var dateLayouts = []timeLayout{
+	{time.RFC3339, time.Second},
+	{"2006-01-02T15:04:05", time.Second},
+	{"2006-01-02T15:04", time.Minute},
+	{"2006-01-02 15:04:05", time.Second},
+	{"2006-01-02 15:04", time.Minute},
+	{"2006-01-02", 24 * time.Hour},
+}
+
+// Layouts accepted for a time of the current day, in the timezone of the user
+var timeOfDayLayouts = []timeLayout{
+	{"15:04:05", time.Second},
+	{"15:04", time.Minute},
+}
+
+// timeArg is a point in the past given as an argument, see parseTimeArg
+type timeArg struct {
+	time time.Time
+
+	// unit is the unit of the smallest field given, like a minute for `09:00`, or 0 for a duration
+	unit time.Duration
+
+	isDuration  bool
+	isTimeOfDay bool
+}
+
+// getEnd returns the last millisecond of the time given, like 09:00:59.999 for `09:00`,
+// so that a time window ending at it includes the whole minute
+func (arg *timeArg) getEnd() time.Time {
+	switch arg.unit {
+	case 0:
+		return arg.time
+	case 24 * time.Hour:
+		// A day is not always 24 hours long
+		return arg.time.AddDate(0, 0, 1).Add(-time.Millisecond)
+	default:
+		return arg.time.Add(arg.unit - time.Millisecond)
+	}
+}
+
+var durationRegexp = regexp.MustCompile(`^(\d+)(w|d|h|m|s)`)
+
+var durationUnits = map[string]time.Duration{
+	"w": 7 * 24 * time.Hour,
+	"d": 24 * time.Hour,
+	"h": time.Hour,
+	"m": time.Minute,
+	"s": time.Second,
+}
+
+// parseDuration parses a duration like "2h", "3d" or "1w2d12h".
+// Unlike time.ParseDuration, it understands days and weeks, but does not accept fractions
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, errors.New("empty duration")
+	}
+
+	var duration time.Duration
+	for remaining := value; remaining != ""; {
+		match := durationRegexp.FindStringSubmatch(remaining)
+		if match == nil {
+			return 0, errors.Errorf("invalid duration `%s`", value)
+		}
+
+		quantity, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid duration `%s`", value)
+		}
+
+		duration += time.Duration(quantity) * durationUnits[match[2]]
+		remaining = remaining[len(match[0]):]
+	}
+
+	return duration, nil
+}
+
+// parseTimeArg parses a point in the past, given either as a duration relative to now
+// ("2h", "1d12h") or as a date ("2026-10-01", "2026-10-01 09:00", "09:00").
+// Dates are interpreted in the location of now
+func parseTimeArg(value string, now time.Time) (time.Time, error) {
+	arg, err := parseTimeArgDetails(value, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return arg.time, nil
+}
+
+// parseTimeArgDetails is like parseTimeArg, but also tells how the point in time was given
+func parseTimeArgDetails(value string, now time.Time) (*timeArg, error) {
+	value = strings.TrimSpace(value)
+
+	if duration, err := parseDuration(value); err == nil {
+		if duration <= 0 {
+			return nil, errors.Errorf("The duration `%s` should be greater than zero", value)
+		}
+
+		return &timeArg{time: now.Add(-duration), isDuration: true}, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if parsed, err := time.ParseInLocation(layout.layout, value, now.Location()); err == nil {
+			parsed, err = checkIsInThePast(value, parsed, now)
+			if err != nil {
+				return nil, err
+			}
+			return &timeArg{time: parsed, unit: layout.unit}, nil
+		}
+	}
+
+	for _, layout := range timeOfDayLayouts {
+		if parsed, err := time.ParseInLocation(layout.layout, value, now.Location()); err == nil {
+			parsed = time.Date(
+				now.Year(), now.Month(), now.Day(),
+				parsed.Hour(), parsed.Minute(), parsed.Second(), 0,
+				now.Location(),
+			)
+			parsed, err = checkIsInThePast(value, parsed, now)
+			if err != nil {
+				return nil, err
+			}
+			return &timeArg{time: parsed, unit: layout.unit, isTimeOfDay: true}, nil
+		}
+	}
+
+	return nil, errors.Errorf(
+		"Invalid time `%s`. Use a duration like `2h` or `3d`, or a date like `2006-01-02 15:04`", value)
+}
+
+func checkIsInThePast(value string, parsed time.Time, now time.Time) (time.Time, error) {
+	if parsed.After(now) {
+		return time.Time{}, errors.Errorf("The time `%s` is in the future", value)
+	}
+
+	return parsed, nil
+}
+
+// getUserLocation returns the preferred timezone of the user, or UTC if it can't be determined
+func (p *Plugin) getUserLocation(userID string) *time.Location {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogError("Unable to get user", "err", appErr)
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(user.GetPreferredTimezone())
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// formatTime formats a time in milliseconds for the messages sent to the user
+func formatTime(millis int64, location *time.Location) string {
+	return time.UnixMilli(millis).In(location).Format("2006-01-02 15:04:05 MST")
+}
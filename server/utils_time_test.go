@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	for name, tc := range map[string]struct {
+		value            string
+		expectedDuration time.Duration
+		expectedError    bool
+	}{
+		"empty":            {value: "", expectedError: true},
+		"minutes":          {value: "30m", expectedDuration: 30 * time.Minute},
+		"hours":            {value: "2h", expectedDuration: 2 * time.Hour},
+		"days":             {value: "3d", expectedDuration: 72 * time.Hour},
+		"weeks":            {value: "1w", expectedDuration: 7 * 24 * time.Hour},
+		"combined":         {value: "1d12h30m", expectedDuration: 36*time.Hour + 30*time.Minute},
+		"missing unit":     {value: "12", expectedError: true},
+		"unknown unit":     {value: "2y", expectedError: true},
+		"fraction":         {value: "1.5h", expectedError: true},
+		"trailing garbage": {value: "2hfoo", expectedError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			duration, err := parseDuration(tc.value)
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, got duration %v", duration)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if duration != tc.expectedDuration {
+				t.Errorf("expected %v, got %v", tc.expectedDuration, duration)
+			}
+		})
+	}
+}
+
+func TestParseTimeArg(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, location)
+
+	for name, tc := range map[string]struct {
+		value         string
+		expectedTime  time.Time
+		expectedError bool
+	}{
+		"duration":             {value: "2h", expectedTime: now.Add(-2 * time.Hour)},
+		"zero duration":        {value: "0h", expectedError: true},
+		"date":                 {value: "2026-10-01", expectedTime: time.Date(2026, 10, 1, 0, 0, 0, 0, location)},
+		"date and time":        {value: "2026-10-01 09:00", expectedTime: time.Date(2026, 10, 1, 9, 0, 0, 0, location)},
+		"date and time with T": {value: "2026-10-01T09:00:30", expectedTime: time.Date(2026, 10, 1, 9, 0, 30, 0, location)},
+		"RFC3339":              {value: "2026-10-01T09:00:00Z", expectedTime: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)},
+		"time of day":          {value: "14:02", expectedTime: time.Date(2026, 10, 18, 14, 2, 0, 0, location)},
+		"time in the future":   {value: "16:00", expectedError: true},
+		"date in the future":   {value: "2027-01-01", expectedError: true},
+		"garbage":              {value: "yesterday", expectedError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := parseTimeArg(tc.value, now)
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, got time %v", parsed)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !parsed.Equal(tc.expectedTime) {
+				t.Errorf("expected %v, got %v", tc.expectedTime, parsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/pkg/errors"
+)
+
+// undoChunkMaxSize is the maximum size of the serialized posts stored in a single KV value.
+// The snapshots of large deletions are split into several chunks to stay below the KV value size limit
+const undoChunkMaxSize = 256 * 1024
+
+// undoChunkExpiryMargin is kept after the grace period before the chunks expire from the KV store,
+// in case the expiry job could not delete them
+const undoChunkExpiryMargin = 24 * time.Hour
+
+// undoSnapshot describes the posts deleted by a user in a channel, to be able to restore them.
+// The posts themselves are stored in NumChunks chunks
+type undoSnapshot struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+	CreateAt  int64  `json:"create_at"`
+	ExpireAt  int64  `json:"expire_at"`
+	NumChunks int    `json:"num_chunks"`
+}
+
+func getUndoSnapshotKey(userID string, channelID string) string {
+	return kvUndoPrefix + userID + "_" + channelID
+}
+
+func getUndoChunkKey(snapshotID string, index int) string {
+	return fmt.Sprintf("%s%s_%d", kvUndoChunkPrefix, snapshotID, index)
+}
+
+// getUndoGracePeriod returns for how long the deleted posts can be restored, or 0 if undo is disabled
+func (p *Plugin) getUndoGracePeriod() time.Duration {
+	return time.Duration(p.getConfiguration().UndoGracePeriodMinutes) * time.Minute
+}
+
+// getPostSnapshot returns the post and, if it is a thread root, all its replies, as they will be deleted
+// along with it. The attached files are copied so they can be attached again to the restored posts.
+// The plugin API can't delete files, so the copies of the posts that are never restored are left unattached:
+// they don't belong to any post nor channel, and only their creator, the author of the post, can still read them
+func (p *Plugin) getPostSnapshot(post *model.Post, replies []*model.Post) ([]*model.Post, error) {
+	posts, err := p.getPostWithThread(post, replies)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make([]*model.Post, 0, len(posts))
+	for _, snapshotPost := range posts {
+		snapshotPost = snapshotPost.Clone()
+		snapshotPost.Metadata = nil
+
+		if len(snapshotPost.FileIds) > 0 {
+			fileIDs, appErr := p.API.CopyFileInfos(snapshotPost.UserId, snapshotPost.FileIds)
+			if appErr != nil {
+				return nil, errors.Wrap(appErr, "failed to copy files")
+			}
+			snapshotPost.FileIds = fileIDs
+		}
+
+		snapshot = append(snapshot, snapshotPost)
+	}
+
+	return snapshot, nil
+}
+
+// splitUndoChunks splits the posts in chunks whose serialized size is at most undoChunkMaxSize,
+// unless a single post is larger
+func splitUndoChunks(posts []*model.Post) ([][]*model.Post, error) {
+	chunks := [][]*model.Post{}
+	current := []*model.Post{}
+	currentSize := 0
+
+	for _, post := range posts {
+		data, err := json.Marshal(post)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(current) > 0 && currentSize+len(data) > undoChunkMaxSize {
+			chunks = append(chunks, current)
+			current = []*model.Post{}
+			currentSize = 0
+		}
+
+		current = append(current, post)
+		currentSize += len(data)
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks, nil
+}
+
+// saveUndoSnapshot keeps the deleted posts, replacing the previous snapshot of the user in this channel
+func (p *Plugin) saveUndoSnapshot(options *deletionOptions, posts []*model.Post) error {
+	chunks, err := splitUndoChunks(posts)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	snapshot := &undoSnapshot{
+		ID:        model.NewId(),
+		UserID:    options.userID,
+		ChannelID: options.channelID,
+		CreateAt:  now.UnixMilli(),
+		ExpireAt:  now.Add(p.getUndoGracePeriod()).UnixMilli(),
+		NumChunks: len(chunks),
+	}
+
+	for i, chunk := range chunks {
+		if _, err := p.client.KV.Set(
+			getUndoChunkKey(snapshot.ID, i), chunk,
+			pluginapi.SetExpiry(p.getUndoGracePeriod()+undoChunkExpiryMargin),
+		); err != nil {
+			p.deleteUndoChunks(snapshot)
+			return errors.Wrapf(err, "failed to save chunk %d of the snapshot", i)
+		}
+	}
+
+	// The previous snapshot, if any, can't be restored anymore
+	previous, err := p.getUndoSnapshot(options.userID, options.channelID)
+	if err != nil {
+		p.API.LogWarn("Unable to get the previous undo snapshot", "err", err)
+	}
+
+	if _, err := p.client.KV.Set(getUndoSnapshotKey(options.userID, options.channelID), snapshot); err != nil {
+		p.deleteUndoChunks(snapshot)
+		return err
+	}
+
+	if previous != nil {
+		p.deleteUndoChunks(previous)
+	}
+
+	return nil
+}
+
+// getUndoSnapshot returns the snapshot of the user in this channel, or nil if there is none or it has expired
+func (p *Plugin) getUndoSnapshot(userID string, channelID string) (*undoSnapshot, error) {
+	var snapshot *undoSnapshot
+	if err := p.client.KV.Get(getUndoSnapshotKey(userID, channelID), &snapshot); err != nil {
+		return nil, err
+	}
+
+	if snapshot == nil || snapshot.ExpireAt < model.GetMillis() {
+		return nil, nil
+	}
+
+	return snapshot, nil
+}
+
+// getUndoSnapshotPosts reads the posts of all the chunks of the snapshot
+func (p *Plugin) getUndoSnapshotPosts(snapshot *undoSnapshot) ([]*model.Post, error) {
+	posts := []*model.Post{}
+
+	for i := 0; i < snapshot.NumChunks; i++ {
+		var chunk []*model.Post
+		if err := p.client.KV.Get(getUndoChunkKey(snapshot.ID, i), &chunk); err != nil {
+			return nil, errors.Wrapf(err, "failed to get chunk %d of the snapshot", i)
+		}
+		posts = append(posts, chunk...)
+	}
+
+	return posts, nil
+}
+
+// deleteUndoChunks deletes the chunks of the snapshot from the KV store
+func (p *Plugin) deleteUndoChunks(snapshot *undoSnapshot) {
+	for i := 0; i < snapshot.NumChunks; i++ {
+		if err := p.client.KV.Delete(getUndoChunkKey(snapshot.ID, i)); err != nil {
+			p.API.LogError("Unable to delete undo chunk", "SnapshotID", snapshot.ID, "index", i, "err", err)
+		}
+	}
+}
+
+// expireUndoSnapshots deletes the snapshots whose grace period is over, along with their chunks.
+// It is run periodically as a background job
+func (p *Plugin) expireUndoSnapshots() {
+	keys, err := p.listKVKeys(kvUndoPrefix)
+	if err != nil {
+		p.API.LogError("Unable to list undo snapshots", "err", err)
+		return
+	}
+
+	now := model.GetMillis()
+	for _, key := range keys {
+		var snapshot *undoSnapshot
+		if err := p.client.KV.Get(key, &snapshot); err != nil {
+			p.API.LogError("Unable to get undo snapshot", "key", key, "err", err)
+			continue
+		}
+
+		if snapshot != nil && snapshot.ExpireAt >= now {
+			continue
+		}
+
+		// Delete the snapshot first, so it can't be restored while its chunks are deleted
+		if err := p.client.KV.Delete(key); err != nil {
+			p.API.LogError("Unable to delete undo snapshot", "key", key, "err", err)
+			continue
+		}
+
+		if snapshot != nil {
+			p.deleteUndoChunks(snapshot)
+		}
+	}
+}
+
+// getUndoMessage tells the user how to restore the posts they just deleted
+func (p *Plugin) getUndoMessage() string {
+	minutes := p.getConfiguration().UndoGracePeriodMinutes
+	return fmt.Sprintf(
+		"Made a mistake? Type `/broom %s` in the next %d minute%s to restore the deleted posts.",
+		undoTrigger, minutes, getPluralChar(minutes),
+	)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitUndoChunks(t *testing.T) {
+	largeMessage := strings.Repeat("a", undoChunkMaxSize/3)
+
+	posts := []*model.Post{}
+	for i := 0; i < 7; i++ {
+		posts = append(posts, &model.Post{Id: model.NewId(), Message: largeMessage})
+	}
+
+	chunks, err := splitUndoChunks(posts)
+	require.NoError(t, err)
+
+	// Only two posts of a third of the maximum size fit in a chunk, along with their other fields
+	require.Len(t, chunks, 4)
+	numPosts := 0
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), 2)
+		numPosts += len(chunk)
+	}
+	assert.Equal(t, len(posts), numPosts)
+	assert.Equal(t, posts[0].Id, chunks[0][0].Id)
+	assert.Equal(t, posts[6].Id, chunks[3][0].Id)
+
+	t.Run("post larger than a chunk", func(t *testing.T) {
+		chunks, err := splitUndoChunks([]*model.Post{{Message: strings.Repeat("a", undoChunkMaxSize+1)}})
+		require.NoError(t, err)
+		assert.Len(t, chunks, 1)
+	})
+
+	t.Run("no post", func(t *testing.T) {
+		chunks, err := splitUndoChunks(nil)
+		require.NoError(t, err)
+		assert.Empty(t, chunks)
+	})
+}
+
+func TestGetPostSnapshot(t *testing.T) {
+	root := &model.Post{Id: "root", UserId: "user", ReplyCount: 2, FileIds: model.StringArray{"file"}}
+	replies := []*model.Post{
+		{Id: "reply2", UserId: "other", RootId: "root"},
+		{Id: "reply1", UserId: "user", RootId: "root"},
+	}
+
+	t.Run("replies already fetched", func(t *testing.T) {
+		api := &plugintest.API{}
+		api.On("CopyFileInfos", "user", []string{"file"}).Return([]string{"copy"}, nil).Once()
+		defer api.AssertExpectations(t)
+
+		p := &Plugin{}
+		p.SetAPI(api)
+
+		snapshot, err := p.getPostSnapshot(root, replies)
+		require.NoError(t, err)
+		require.Len(t, snapshot, 3)
+		assert.Equal(t, model.StringArray{"copy"}, snapshot[0].FileIds)
+		assert.Equal(t, model.StringArray{"file"}, root.FileIds, "the original post should not be changed")
+		api.AssertNotCalled(t, "GetPostThread", mock.Anything)
+	})
+
+	t.Run("replies missing", func(t *testing.T) {
+		thread := model.NewPostList()
+		for _, post := range append([]*model.Post{root}, replies...) {
+			thread.AddPost(post)
+			thread.AddOrder(post.Id)
+		}
+
+		api := &plugintest.API{}
+		api.On("GetPostThread", "root").Return(thread, nil).Once()
+		api.On("CopyFileInfos", "user", []string{"file"}).Return([]string{"copy"}, nil).Once()
+		defer api.AssertExpectations(t)
+
+		p := &Plugin{}
+		p.SetAPI(api)
+
+		snapshot, err := p.getPostSnapshot(root, replies[:1])
+		require.NoError(t, err)
+		assert.Len(t, snapshot, 3)
+	})
+}